@@ -17,6 +17,63 @@ func ImportData(ctx context.Context, config *Config) error {
 		BatchSize:     config.BatchSize,
 		Workers:       config.Workers,
 		ProgressEvery: config.ProgressEvery,
+		InsertMethod:  config.InsertMethod,
+		OnConflict:    config.OnConflict,
+		ConflictKeys:  config.ConflictKeys,
+		UpdateColumns: config.UpdateColumns,
+		ColumnMapping: config.ColumnMapping,
+
+		DisableTypeCoercion: config.DisableTypeCoercion,
+
+		CreateTable:       config.CreateTable,
+		CreateTableSample: config.CreateTableSample,
+		CreateTableDryRun: config.CreateTableDryRun,
+
+		RejectFile:    config.RejectFile,
+		MaxErrors:     config.MaxErrors,
+		MaxErrorRatio: config.MaxErrorRatio,
+
+		CheckpointFile: config.CheckpointFile,
+		Resume:         config.Resume,
+
+		Transactional: config.Transactional,
+		LoadStrategy:  config.LoadStrategy,
+		DeleteWhere:   config.DeleteWhere,
+
+		ParseWorkers: config.ParseWorkers,
+
+		InputCompression: config.InputCompression,
+		InputEncoding:    config.InputEncoding,
+
+		Columns:              config.Columns,
+		JSONLDiscovery:       config.JSONLDiscovery,
+		JSONLDiscoverySample: config.JSONLDiscoverySample,
+		JSONLNested:          config.JSONLNested,
+		JSONLSeparator:       config.JSONLSeparator,
+		JSONLMaxDepth:        config.JSONLMaxDepth,
+		JSONLArrays:          config.JSONLArrays,
+		JSONPointer:          config.JSONPointer,
+
+		XLSXSheet:         config.XLSXSheet,
+		XLSXSheetIndex:    config.XLSXSheetIndex,
+		XLSXHeaderRow:     config.XLSXHeaderRow,
+		XLSXDataStartRow:  config.XLSXDataStartRow,
+		XLSXSheets:        config.XLSXSheets,
+		XLSXSheetColumn:   config.XLSXSheetColumn,
+		XLSXTableTemplate: config.XLSXTableTemplate,
+		XLSXReader:        config.XLSXReader,
+		XLSXMaxSize:       config.XLSXMaxSize,
+		XLSXTypedValues:   config.XLSXTypedValues,
+
+		CSVDelimiter:    config.CSVDelimiter,
+		CSVQuote:        config.CSVQuote,
+		CSVEscape:       config.CSVEscape,
+		CSVComment:      config.CSVComment,
+		CSVNoHeader:     config.CSVNoHeader,
+		CSVSkipLines:    config.CSVSkipLines,
+		CSVRagged:       config.CSVRagged,
+		CSVKeepSpaces:   config.CSVKeepSpaces,
+		CSVStrictQuotes: config.CSVStrictQuotes,
 	}
 	return importer.ImportData(ctx, importConfig)
 }
@@ -24,13 +81,22 @@ func ImportData(ctx context.Context, config *Config) error {
 // ExportData bridges to the exporter package
 func ExportData(ctx context.Context, config *Config) error {
 	exportConfig := &exporter.Config{
-		DSN:           config.DSN,
-		OutputFile:    config.OutputFile,
-		OutputFormat:  config.OutputFormat,
-		Query:         config.Query,
-		BatchSize:     config.BatchSize,
-		Workers:       config.Workers,
-		ProgressEvery: config.ProgressEvery,
+		DSN:               config.DSN,
+		OutputFile:        config.OutputFile,
+		OutputFormat:      config.OutputFormat,
+		Query:             config.Query,
+		OutputCompression: config.OutputCompression,
+		CompressionLevel:  config.CompressionLevel,
+		OutputEncoding:    config.OutputEncoding,
+		BatchSize:         config.BatchSize,
+		Workers:           config.Workers,
+		ProgressEvery:     config.ProgressEvery,
+		CSVDelimiter:      config.CSVDelimiter,
+		CSVQuote:          config.CSVQuote,
+		CSVEscape:         config.CSVEscape,
+		CSVNoHeader:       config.CSVNoHeader,
+		CSVQuoteAll:       config.CSVQuoteAll,
+		CSVCRLF:           config.CSVCRLF,
 	}
 	return exporter.ExportData(ctx, exportConfig)
 }
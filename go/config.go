@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/datamill/data-engine/go/exporter"
+	"github.com/datamill/data-engine/go/importer"
+	"github.com/datamill/data-engine/go/textfile"
+	"golang.org/x/text/encoding/unicode"
 )
 
 // Config represents the complete configuration for import/export operations
@@ -18,14 +28,135 @@ type Config struct {
 	ProgressEvery  int    `json:"progress_every"`  // Report progress every N rows
 
 	// Import-specific fields
-	InputFile   string `json:"input_file"`   // Path to input file
-	InputFormat string `json:"input_format"` // "auto", "csv", "tsv", "jsonl", "xlsx"
-	Table       string `json:"table"`        // Target database table
+	InputFile    string `json:"input_file"`    // Path to input file
+	InputFormat  string `json:"input_format"`  // "auto", "csv", "tsv", "jsonl", "json", "xlsx", "parquet"
+	Table        string `json:"table"`         // Target database table
+	InsertMethod string `json:"insert_method"` // "insert", "copy", "copy-binary" (PostgreSQL), "load-data" (MySQL)
+
+	// Input compression: "auto" (from the extension, e.g. .csv.gz, or the
+	// magic bytes), "none", "gzip", "zstd", "bzip2", "xz" or "zip" (an
+	// archive of one or more data files, read in order)
+	InputCompression string `json:"input_compression"`
+
+	// Character encoding of text input: "auto" (UTF-8, or UTF-16 when the
+	// file starts with a byte order mark) or an IANA name such as
+	// "windows-1252", "ISO-8859-1" or "UTF-16LE". Byte order marks are
+	// stripped either way.
+	InputEncoding string `json:"input_encoding"`
+
+	// Conflict handling for import
+	OnConflict    string   `json:"on_conflict"`    // "error", "skip", "update-all", "update-selected"
+	ConflictKeys  []string `json:"conflict_keys"`  // Key columns identifying a duplicate row
+	UpdateColumns []string `json:"update_columns"` // Columns to overwrite with "update-selected"
+
+	// Column mapping from file header to table columns
+	ColumnMapping *importer.ColumnMapping `json:"column_mapping"`
+
+	// Values are converted to the target column types unless disabled
+	DisableTypeCoercion bool `json:"disable_type_coercion"`
+
+	// Create the target table from types inferred from the first rows
+	CreateTable       bool `json:"create_table"`
+	CreateTableSample int  `json:"create_table_sample"`  // Rows sampled for inference (default 1000)
+	CreateTableDryRun bool `json:"create_table_dry_run"` // Print the DDL to stdout without executing it
+
+	// Bad rows: rejected rows are written to RejectFile and the import only
+	// fails once the budget is used up (both zero = fail on the first one)
+	RejectFile    string  `json:"reject_file"`     // JSONL file of rejected rows with line and error
+	MaxErrors     int64   `json:"max_errors"`      // Rejected rows tolerated before failing
+	MaxErrorRatio float64 `json:"max_error_ratio"` // Fraction of rows (0-1) tolerated before failing
+
+	// Progress is saved to CheckpointFile as batches commit so an interrupted
+	// import can be resumed without loading rows twice
+	CheckpointFile string `json:"checkpoint_file"`
+	Resume         bool   `json:"resume"` // Skip rows recorded in the checkpoint file
+
+	// Load into a staging table and publish every row in one transaction at
+	// the end, so a failed import leaves the target table untouched
+	Transactional bool `json:"transactional"`
+
+	// What happens to rows already in the table: "append", "truncate",
+	// "delete-where" (rows matching DeleteWhere) or "swap" (load a shadow
+	// copy and rename it over the table)
+	LoadStrategy string `json:"load_strategy"`
+	DeleteWhere  string `json:"delete_where"` // SQL predicate for "delete-where"
+
+	// Goroutines parsing csv, tsv and jsonl input in separate byte ranges
+	// (1 = a single reader)
+	ParseWorkers int `json:"parse_workers"`
+
+	// Input columns, in order, instead of discovering them from the file
+	// (jsonl, json). For parquet only these columns are decoded.
+	Columns []string `json:"columns"`
+
+	// JSONL column discovery: "first-line" (keys of the first object),
+	// "sample" (keys of the first JSONLDiscoverySample lines) or "full"
+	// (keys of every line, reading the file twice)
+	JSONLDiscovery       string `json:"jsonl_discovery"`
+	JSONLDiscoverySample int    `json:"jsonl_discovery_sample"` // Lines scanned by "sample" (default 1000)
+
+	// Nested JSONL values: "json" keeps objects and arrays as JSON text (for
+	// jsonb/JSON columns), "flatten" turns object paths into columns
+	JSONLNested    string `json:"jsonl_nested"`
+	JSONLSeparator string `json:"jsonl_separator"` // Joins flattened keys: "_" or "."
+	JSONLMaxDepth  int    `json:"jsonl_max_depth"` // Most keys in a flattened column (0 = no limit)
+	JSONLArrays    string `json:"jsonl_arrays"`    // "json" or "explode" (one row per element)
+
+	// JSON pointer to the array of objects in a "json" file, e.g. "/data"
+	// ("" = the top-level value). The jsonl_* options apply to its objects.
+	JSONPointer string `json:"json_pointer"`
+
+	// XLSX sheets and rows. Row numbers start at 1. XLSXSheets is "single"
+	// (one sheet by name or index), "combined" (every sheet into the table,
+	// naming the sheet in XLSXSheetColumn) or "per-sheet" (every sheet into
+	// its own table, named by XLSXTableTemplate)
+	XLSXSheet         string `json:"xlsx_sheet"`          // Sheet name
+	XLSXSheetIndex    int    `json:"xlsx_sheet_index"`    // Sheet position, starting at 1
+	XLSXHeaderRow     int    `json:"xlsx_header_row"`     // Row holding the column names (default 1)
+	XLSXDataStartRow  int    `json:"xlsx_data_start_row"` // First data row (default: after the header)
+	XLSXSheets        string `json:"xlsx_sheets"`
+	XLSXSheetColumn   string `json:"xlsx_sheet_column"`   // Column receiving the sheet name (default "sheet_name")
+	XLSXTableTemplate string `json:"xlsx_table_template"` // e.g. "{table}_{sheet}"; also {index}
+
+	// XLSX reader: "excelize" loads the workbook and refuses files over
+	// XLSXMaxSize, "stream" reads worksheet XML straight from the zip with
+	// shared strings spilled to disk, "auto" streams files over XLSXMaxSize
+	XLSXReader  string `json:"xlsx_reader"`
+	XLSXMaxSize int64  `json:"xlsx_max_size"` // Bytes (default 100MB)
+
+	// Read XLSX cells with their types instead of as displayed text: numbers,
+	// booleans, dates from date-formatted serials (1900 or 1904 epoch) and
+	// cached formula results. Uses the streaming reader.
+	XLSXTypedValues bool `json:"xlsx_typed_values"`
+
+	// CSV and TSV dialect, for import and export. Characters are given as
+	// one-character strings; empty settings keep the format's defaults.
+	CSVDelimiter    string `json:"csv_delimiter"`     // Default "," for csv, tab for tsv
+	CSVQuote        string `json:"csv_quote"`         // Default "\""; "none" for files that are never quoted
+	CSVEscape       string `json:"csv_escape"`        // "double" (default) or "backslash"
+	CSVNoHeader     bool   `json:"csv_no_header"`     // Import: columns named column_1, column_2, ...; export: no header row
+	CSVComment      string `json:"csv_comment"`       // Import: lines starting with it are skipped
+	CSVSkipLines    int    `json:"csv_skip_lines"`    // Import: lines skipped before the header
+	CSVRagged       string `json:"csv_ragged"`        // Import: "error" (default), "pad" or "truncate"
+	CSVKeepSpaces   bool   `json:"csv_keep_spaces"`   // Import: keep leading spaces of fields
+	CSVStrictQuotes bool   `json:"csv_strict_quotes"` // Import: reject bare and stray quotes
+	CSVQuoteAll     bool   `json:"csv_quote_all"`     // Export: quote every field
+	CSVCRLF         bool   `json:"csv_crlf"`          // Export: end lines with \r\n
 
 	// Export-specific fields
 	OutputFile   string `json:"output_file"`   // Path to output file
 	OutputFormat string `json:"output_format"` // "csv", "tsv", "jsonl", "parquet"
 	Query        string `json:"query"`         // SQL query for export
+
+	// Compress csv, tsv and jsonl output: "auto" (from the output extension,
+	// e.g. .csv.gz or .jsonl.zst), "none", "gzip" or "zstd". gzip blocks are
+	// compressed on all workers.
+	OutputCompression string `json:"output_compression"`
+	CompressionLevel  int    `json:"compression_level"` // gzip 1-9, zstd 1-22 (0 = codec default)
+
+	// Character encoding of csv and tsv output, an IANA name such as
+	// "utf-8" (the default), "windows-1252" or "UTF-16LE"
+	OutputEncoding string `json:"output_encoding"`
 }
 
 // Validate checks if the configuration is valid
@@ -58,6 +189,10 @@ func (c *Config) Validate() error {
 		c.ProgressEvery = 100000 // Default
 	}
 
+	if err := c.validateCSV(); err != nil {
+		return err
+	}
+
 	// Mode-specific validation
 	if c.Mode == "import" {
 		return c.validateImport()
@@ -84,14 +219,295 @@ func (c *Config) validateImport() error {
 	if c.InputFormat == "" {
 		c.InputFormat = "auto"
 	}
-	validFormats := []string{"auto", "csv", "tsv", "jsonl", "xlsx"}
+	validFormats := []string{"auto", "csv", "tsv", "jsonl", "json", "xlsx", "parquet"}
 	if !contains(validFormats, c.InputFormat) {
 		return fmt.Errorf("invalid input_format: %s (must be one of: %s)", c.InputFormat, strings.Join(validFormats, ", "))
 	}
 
+	// Validate compression
+	if c.InputCompression == "" {
+		c.InputCompression = importer.CompressionAuto
+	}
+	validCompressions := []string{importer.CompressionAuto, importer.CompressionNone, importer.CompressionGzip,
+		importer.CompressionZstd, importer.CompressionBzip2, importer.CompressionXz, importer.CompressionZip}
+	if !contains(validCompressions, c.InputCompression) {
+		return fmt.Errorf("invalid input_compression: %s (must be one of: %s)", c.InputCompression, strings.Join(validCompressions, ", "))
+	}
+	compressed := c.InputCompression != importer.CompressionAuto && c.InputCompression != importer.CompressionNone
+	if compressed && (c.InputFormat == "xlsx" || c.InputFormat == "parquet") {
+		return fmt.Errorf("%s input cannot be read from a %s file, decompress it first", c.InputFormat, c.InputCompression)
+	}
+
+	// Validate encoding
+	if c.InputEncoding == "" {
+		c.InputEncoding = importer.EncodingAuto
+	}
+	if c.InputEncoding != importer.EncodingAuto {
+		if _, err := textfile.LookupEncoding(c.InputEncoding); err != nil {
+			return fmt.Errorf("invalid input_encoding: %w", err)
+		}
+		if c.InputFormat == "xlsx" || c.InputFormat == "parquet" {
+			return fmt.Errorf("input_encoding is not supported for %s input", c.InputFormat)
+		}
+	}
+
+	// Validate insert method
+	if c.InsertMethod == "" {
+		c.InsertMethod = "insert"
+	}
+	validMethods := []string{"insert", "copy", "copy-binary", "load-data"}
+	if !contains(validMethods, c.InsertMethod) {
+		return fmt.Errorf("invalid insert_method: %s (must be one of: %s)", c.InsertMethod, strings.Join(validMethods, ", "))
+	}
+	if c.InsertMethod == "copy-binary" && c.DisableTypeCoercion {
+		return fmt.Errorf("insert_method copy-binary cannot be used with disable_type_coercion: binary COPY needs values converted to the column types")
+	}
+
+	// Validate table creation
+	if c.CreateTableSample <= 0 {
+		c.CreateTableSample = importer.DefaultSchemaSample
+	}
+	if c.CreateTableDryRun && !c.CreateTable {
+		return fmt.Errorf("create_table_dry_run requires create_table")
+	}
+
+	// Validate error budget
+	if c.MaxErrors < 0 {
+		return fmt.Errorf("max_errors cannot be negative: %d", c.MaxErrors)
+	}
+	if c.MaxErrorRatio < 0 || c.MaxErrorRatio > 1 {
+		return fmt.Errorf("max_error_ratio must be between 0 and 1: %g", c.MaxErrorRatio)
+	}
+
+	// Validate checkpointing
+	if c.Resume && c.CheckpointFile == "" {
+		return fmt.Errorf("resume requires checkpoint_file")
+	}
+	if c.Transactional && c.CheckpointFile != "" {
+		return fmt.Errorf("transactional imports cannot be resumed, remove checkpoint_file")
+	}
+
+	// Validate parallel parsing
+	if c.ParseWorkers < 0 {
+		return fmt.Errorf("parse_workers cannot be negative: %d", c.ParseWorkers)
+	}
+	if c.ParseWorkers == 0 {
+		c.ParseWorkers = 1
+	}
+	if c.ParseWorkers > 1 && (c.InputFormat == "xlsx" || c.InputFormat == "json" || c.InputFormat == "parquet") {
+		return fmt.Errorf("parse_workers requires csv, tsv or jsonl input, got: %s", c.InputFormat)
+	}
+	if c.ParseWorkers > 1 && c.CheckpointFile != "" {
+		return fmt.Errorf("parse_workers cannot be combined with checkpoint_file, rows are read out of order")
+	}
+
+	// Validate JSONL column discovery
+	if c.JSONLDiscovery == "" {
+		c.JSONLDiscovery = importer.DiscoverFirstLine
+	}
+	validDiscovery := []string{importer.DiscoverFirstLine, importer.DiscoverSample, importer.DiscoverFull}
+	if !contains(validDiscovery, c.JSONLDiscovery) {
+		return fmt.Errorf("invalid jsonl_discovery: %s (must be one of: %s)", c.JSONLDiscovery, strings.Join(validDiscovery, ", "))
+	}
+	if c.JSONLDiscoverySample <= 0 {
+		c.JSONLDiscoverySample = importer.DefaultDiscoverySample
+	}
+
+	// Validate JSONL nesting
+	if c.JSONLNested == "" {
+		c.JSONLNested = importer.NestedJSON
+	}
+	if c.JSONLNested != importer.NestedJSON && c.JSONLNested != importer.NestedFlatten {
+		return fmt.Errorf("invalid jsonl_nested: %s (must be one of: json, flatten)", c.JSONLNested)
+	}
+	if c.JSONLSeparator == "" {
+		c.JSONLSeparator = "_"
+	}
+	if c.JSONLSeparator != "_" && c.JSONLSeparator != "." {
+		return fmt.Errorf("invalid jsonl_separator: %q (must be \"_\" or \".\")", c.JSONLSeparator)
+	}
+	if c.JSONLMaxDepth < 0 {
+		return fmt.Errorf("jsonl_max_depth cannot be negative: %d", c.JSONLMaxDepth)
+	}
+	if c.JSONLArrays == "" {
+		c.JSONLArrays = importer.ArraysJSON
+	}
+	if c.JSONLArrays != importer.ArraysJSON && c.JSONLArrays != importer.ArraysExplode {
+		return fmt.Errorf("invalid jsonl_arrays: %s (must be one of: json, explode)", c.JSONLArrays)
+	}
+	if c.JSONLArrays == importer.ArraysExplode && c.JSONLNested != importer.NestedFlatten {
+		return fmt.Errorf("jsonl_arrays explode requires jsonl_nested flatten")
+	}
+	if c.JSONLArrays == importer.ArraysExplode && c.CheckpointFile != "" {
+		return fmt.Errorf("jsonl_arrays explode cannot be combined with checkpoint_file, lines no longer map to rows")
+	}
+
+	// Validate XLSX sheet selection
+	if c.XLSXSheets == "" {
+		c.XLSXSheets = importer.SheetsSingle
+	}
+	validSheets := []string{importer.SheetsSingle, importer.SheetsCombined, importer.SheetsPerTable}
+	if !contains(validSheets, c.XLSXSheets) {
+		return fmt.Errorf("invalid xlsx_sheets: %s (must be one of: %s)", c.XLSXSheets, strings.Join(validSheets, ", "))
+	}
+	if c.XLSXSheet != "" && c.XLSXSheetIndex != 0 {
+		return fmt.Errorf("xlsx_sheet and xlsx_sheet_index cannot both be set")
+	}
+	if c.XLSXSheetIndex < 0 {
+		return fmt.Errorf("xlsx_sheet_index starts at 1: %d", c.XLSXSheetIndex)
+	}
+	if c.XLSXSheets != importer.SheetsSingle && (c.XLSXSheet != "" || c.XLSXSheetIndex != 0) {
+		return fmt.Errorf("xlsx_sheet and xlsx_sheet_index select a single sheet, remove them for xlsx_sheets %s", c.XLSXSheets)
+	}
+	if c.XLSXHeaderRow < 0 {
+		return fmt.Errorf("xlsx_header_row starts at 1: %d", c.XLSXHeaderRow)
+	}
+	if c.XLSXHeaderRow == 0 {
+		c.XLSXHeaderRow = 1
+	}
+	if c.XLSXDataStartRow == 0 {
+		c.XLSXDataStartRow = c.XLSXHeaderRow + 1
+	}
+	if c.XLSXDataStartRow <= c.XLSXHeaderRow {
+		return fmt.Errorf("xlsx_data_start_row must come after xlsx_header_row %d: %d", c.XLSXHeaderRow, c.XLSXDataStartRow)
+	}
+	if c.XLSXSheets == importer.SheetsCombined && c.XLSXSheetColumn == "" {
+		c.XLSXSheetColumn = "sheet_name"
+	}
+	if c.XLSXSheets != importer.SheetsCombined && c.XLSXSheetColumn != "" {
+		return fmt.Errorf("xlsx_sheet_column requires xlsx_sheets combined")
+	}
+	if c.XLSXSheets == importer.SheetsPerTable {
+		if !strings.Contains(c.XLSXTableTemplate, "{sheet}") && !strings.Contains(c.XLSXTableTemplate, "{index}") {
+			return fmt.Errorf("xlsx_sheets per-sheet requires xlsx_table_template with {sheet} or {index}, e.g. {table}_{sheet}")
+		}
+		if c.CheckpointFile != "" {
+			return fmt.Errorf("xlsx_sheets per-sheet cannot be combined with checkpoint_file")
+		}
+	} else if c.XLSXTableTemplate != "" {
+		return fmt.Errorf("xlsx_table_template requires xlsx_sheets per-sheet")
+	}
+	if c.XLSXSheets == importer.SheetsCombined && c.CheckpointFile != "" {
+		return fmt.Errorf("xlsx_sheets combined cannot be combined with checkpoint_file")
+	}
+
+	// Validate XLSX reader
+	if c.XLSXReader == "" {
+		c.XLSXReader = importer.XLSXReaderAuto
+	}
+	validReaders := []string{importer.XLSXReaderAuto, importer.XLSXReaderExcelize, importer.XLSXReaderStream}
+	if !contains(validReaders, c.XLSXReader) {
+		return fmt.Errorf("invalid xlsx_reader: %s (must be one of: %s)", c.XLSXReader, strings.Join(validReaders, ", "))
+	}
+	if c.XLSXMaxSize < 0 {
+		return fmt.Errorf("xlsx_max_size cannot be negative: %d", c.XLSXMaxSize)
+	}
+	if c.XLSXMaxSize == 0 {
+		c.XLSXMaxSize = importer.DefaultXLSXMaxSize
+	}
+	if c.XLSXTypedValues && c.XLSXReader == importer.XLSXReaderExcelize {
+		return fmt.Errorf("xlsx_typed_values uses the streaming reader, remove xlsx_reader excelize")
+	}
+
+	// Validate load strategy
+	if c.LoadStrategy == "" {
+		c.LoadStrategy = "append"
+	}
+	validStrategies := []string{"append", "truncate", "delete-where", "swap"}
+	if !contains(validStrategies, c.LoadStrategy) {
+		return fmt.Errorf("invalid load_strategy: %s (must be one of: %s)", c.LoadStrategy, strings.Join(validStrategies, ", "))
+	}
+	if c.LoadStrategy == "delete-where" && strings.TrimSpace(c.DeleteWhere) == "" {
+		return fmt.Errorf("load_strategy delete-where requires delete_where")
+	}
+	if c.LoadStrategy != "delete-where" && c.DeleteWhere != "" {
+		return fmt.Errorf("delete_where requires load_strategy delete-where")
+	}
+	if c.LoadStrategy == "swap" && c.Transactional {
+		return fmt.Errorf("load_strategy swap already publishes atomically, remove transactional")
+	}
+	if c.LoadStrategy == "swap" && c.CheckpointFile != "" {
+		return fmt.Errorf("load_strategy swap cannot be resumed, remove checkpoint_file")
+	}
+
+	// Validate conflict handling
+	if c.OnConflict == "" {
+		c.OnConflict = "error"
+	}
+	validConflicts := []string{"error", "skip", "update-all", "update-selected"}
+	if !contains(validConflicts, c.OnConflict) {
+		return fmt.Errorf("invalid on_conflict: %s (must be one of: %s)", c.OnConflict, strings.Join(validConflicts, ", "))
+	}
+	if (c.OnConflict == "update-all" || c.OnConflict == "update-selected") && len(c.ConflictKeys) == 0 {
+		return fmt.Errorf("conflict_keys is required for on_conflict %s", c.OnConflict)
+	}
+	if c.OnConflict == "update-selected" && len(c.UpdateColumns) == 0 {
+		return fmt.Errorf("update_columns is required for on_conflict update-selected")
+	}
+	if c.OnConflict != "update-selected" && len(c.UpdateColumns) > 0 {
+		return fmt.Errorf("update_columns can only be used with on_conflict update-selected")
+	}
+
+	return nil
+}
+
+// validateCSV validates the CSV dialect settings
+func (c *Config) validateCSV() error {
+	for name, value := range map[string]string{"csv_delimiter": c.CSVDelimiter, "csv_comment": c.CSVComment} {
+		if value != "" && !validCSVChar(value) {
+			return fmt.Errorf("invalid %s: %q (must be a single character other than a line break)", name, value)
+		}
+	}
+	if c.CSVQuote != "" && c.CSVQuote != textfile.QuoteNone && !validCSVChar(c.CSVQuote) {
+		return fmt.Errorf("invalid csv_quote: %q (must be a single character or none)", c.CSVQuote)
+	}
+
+	quote := c.CSVQuote
+	if quote == "" {
+		quote = "\""
+	}
+	switch {
+	case c.CSVDelimiter != "" && c.CSVDelimiter == quote:
+		return fmt.Errorf("csv_delimiter and csv_quote must differ")
+	case c.CSVComment != "" && (c.CSVComment == c.CSVDelimiter || c.CSVComment == quote):
+		return fmt.Errorf("csv_comment must differ from csv_delimiter and csv_quote")
+	}
+
+	if c.CSVEscape == "" {
+		c.CSVEscape = textfile.EscapeDouble
+	}
+	validEscapes := []string{textfile.EscapeDouble, textfile.EscapeBackslash}
+	if !contains(validEscapes, c.CSVEscape) {
+		return fmt.Errorf("invalid csv_escape: %s (must be one of: %s)", c.CSVEscape, strings.Join(validEscapes, ", "))
+	}
+	if c.CSVEscape == textfile.EscapeBackslash && (c.CSVDelimiter == "\\" || c.CSVQuote == "\\") {
+		return fmt.Errorf("csv_escape backslash cannot be used with a backslash delimiter or quote")
+	}
+
+	if c.CSVSkipLines < 0 {
+		return fmt.Errorf("csv_skip_lines cannot be negative: %d", c.CSVSkipLines)
+	}
+	if c.CSVRagged == "" {
+		c.CSVRagged = importer.RaggedError
+	}
+	validRagged := []string{importer.RaggedError, importer.RaggedPad, importer.RaggedTruncate}
+	if !contains(validRagged, c.CSVRagged) {
+		return fmt.Errorf("invalid csv_ragged: %s (must be one of: %s)", c.CSVRagged, strings.Join(validRagged, ", "))
+	}
+	if c.CSVQuoteAll && c.CSVQuote == textfile.QuoteNone {
+		return fmt.Errorf("csv_quote_all cannot be used with csv_quote none")
+	}
+
 	return nil
 }
 
+// validCSVChar reports whether a dialect setting is one character that can
+// separate or quote fields
+func validCSVChar(value string) bool {
+	ch, size := utf8.DecodeRuneInString(value)
+	return size == len(value) && ch != utf8.RuneError && ch != '\r' && ch != '\n'
+}
+
 // validateExport validates export-specific configuration
 func (c *Config) validateExport() error {
 	// Validate output file
@@ -113,6 +529,37 @@ func (c *Config) validateExport() error {
 		return fmt.Errorf("invalid output_format: %s (must be one of: %s)", c.OutputFormat, strings.Join(validFormats, ", "))
 	}
 
+	// Validate compression
+	if c.OutputCompression == "" {
+		c.OutputCompression = exporter.CompressionAuto
+	}
+	validCompressions := []string{exporter.CompressionAuto, exporter.CompressionNone, exporter.CompressionGzip, exporter.CompressionZstd}
+	if !contains(validCompressions, c.OutputCompression) {
+		return fmt.Errorf("invalid output_compression: %s (must be one of: %s)", c.OutputCompression, strings.Join(validCompressions, ", "))
+	}
+	if c.OutputFormat == "parquet" && (c.OutputCompression == exporter.CompressionGzip || c.OutputCompression == exporter.CompressionZstd) {
+		return fmt.Errorf("output_compression %s is not supported for parquet output", c.OutputCompression)
+	}
+	maxLevel := exporter.MaxGzipLevel
+	if c.OutputCompression == exporter.CompressionZstd {
+		maxLevel = exporter.MaxZstdLevel
+	}
+	if c.CompressionLevel < 0 || c.CompressionLevel > maxLevel {
+		return fmt.Errorf("compression_level out of range: %d (must be 0-%d)", c.CompressionLevel, maxLevel)
+	}
+
+	// Validate encoding
+	if c.OutputEncoding == "" {
+		c.OutputEncoding = "utf-8"
+	}
+	enc, err := textfile.LookupEncoding(c.OutputEncoding)
+	if err != nil {
+		return fmt.Errorf("invalid output_encoding: %w", err)
+	}
+	if enc != unicode.UTF8 && c.OutputFormat != "csv" && c.OutputFormat != "tsv" {
+		return fmt.Errorf("output_encoding %s is not supported for %s output, which is always UTF-8", c.OutputEncoding, c.OutputFormat)
+	}
+
 	return nil
 }
 
@@ -123,23 +570,111 @@ func (c *Config) Normalize() error {
 		c.Workers = runtime.NumCPU()
 	}
 
+	// Auto-detect input compression, then the format of what it holds
+	if c.Mode == "import" && c.InputCompression == importer.CompressionAuto {
+		detected, err := importer.DetectCompression(c.InputFile)
+		if err != nil {
+			return fmt.Errorf("failed to detect input compression: %w", err)
+		}
+		c.InputCompression = detected
+		if detected != importer.CompressionNone {
+			fmt.Fprintf(os.Stderr, "[INFO] Auto-detected compression: %s\n", detected)
+		}
+	}
+
+	// Output compression follows the output extension
+	if c.Mode == "export" && c.OutputCompression == exporter.CompressionAuto {
+		c.OutputCompression = exporter.CompressionNone
+		if c.OutputFormat != "parquet" {
+			c.OutputCompression = exporter.DetectCompression(c.OutputFile)
+		}
+		if c.OutputCompression != exporter.CompressionNone {
+			fmt.Fprintf(os.Stderr, "[INFO] Auto-detected output compression: %s\n", c.OutputCompression)
+		}
+	}
+
 	// Auto-detect input format for import
 	if c.Mode == "import" && c.InputFormat == "auto" {
-		detected, err := detectFormat(c.InputFile)
+		detected, err := detectFormat(c.InputFile, c.InputCompression, c.InputEncoding, c.JSONPointer)
 		if err != nil {
 			return fmt.Errorf("failed to detect input format: %w", err)
 		}
 		c.InputFormat = detected
 		fmt.Fprintf(os.Stderr, "[INFO] Auto-detected format: %s\n", detected)
+
+		if detected == "csv" || detected == "tsv" {
+			if err := c.sniffCSV(); err != nil {
+				return fmt.Errorf("failed to detect CSV dialect: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sniffCSV guesses the delimiter and header of an auto-detected CSV or TSV
+// file from its first rows and logs the dialect so it can be pinned. A
+// configured csv_delimiter is kept.
+func (c *Config) sniffCSV() error {
+	input, err := importer.OpenInput(c.InputFile, c.InputCompression, c.InputEncoding)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	sample := make([]byte, importer.SniffSize)
+	n, err := io.ReadFull(input, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	complete := n < len(sample)
+
+	quote := textfile.QuoteRune(c.CSVQuote)
+	dialect := importer.CSVDialect{Quote: quote, Escape: c.CSVEscape, SkipLines: c.CSVSkipLines}
+	if c.CSVDelimiter != "" {
+		dialect.Delimiter, _ = utf8.DecodeRuneInString(c.CSVDelimiter)
+	}
+	if c.CSVComment != "" {
+		dialect.Comment, _ = utf8.DecodeRuneInString(c.CSVComment)
 	}
 
+	sniff := importer.SniffCSV(sample[:n], complete, dialect)
+	c.CSVDelimiter = string(sniff.Delimiter)
+	if sniff.Delimiter == '\t' {
+		c.InputFormat = "tsv"
+	} else if c.InputFormat == "tsv" {
+		c.InputFormat = "csv"
+	}
+	if !sniff.Header {
+		c.CSVNoHeader = true
+	}
+
+	encoding := c.InputEncoding
+	if bom := input.BOM(); bom != "" {
+		encoding = bom + " (byte order mark)"
+	} else if encoding == importer.EncodingAuto {
+		encoding = "utf-8"
+	}
+	fmt.Fprintf(os.Stderr, "[INFO] Detected CSV dialect: delimiter %q, header %v, encoding %s (set input_format, csv_delimiter and csv_no_header to pin it)\n",
+		c.CSVDelimiter, !c.CSVNoHeader, encoding)
 	return nil
 }
 
-// detectFormat attempts to detect file format from extension and content
-func detectFormat(filePath string) (string, error) {
+// detectFormat attempts to detect file format from extension and content.
+// Compressed files are detected from the name without the compression
+// extension and from their decompressed content.
+func detectFormat(filePath, compression, encoding, jsonPointer string) (string, error) {
+	name, err := importer.InputName(filePath, compression)
+	if err != nil {
+		return "", err
+	}
+	compressed := compression != importer.CompressionNone
+
 	// Check extension first
-	ext := strings.ToLower(filepath.Ext(filePath))
+	ext := strings.ToLower(filepath.Ext(name))
+	if compressed && (ext == ".xlsx" || ext == ".parquet") {
+		return "", fmt.Errorf("%s input cannot be read from a %s file, decompress it first", ext[1:], compression)
+	}
 	switch ext {
 	case ".csv":
 		return "csv", nil
@@ -149,38 +684,110 @@ func detectFormat(filePath string) (string, error) {
 		return "jsonl", nil
 	case ".xlsx":
 		return "xlsx", nil
+	case ".parquet":
+		return "parquet", nil
 	case ".xls":
 		return "", fmt.Errorf("XLS format is not supported (legacy Excel format). Please convert to XLSX or CSV")
 	case ".json":
 		// Check if it's JSONL or JSON array
-		return "", fmt.Errorf("JSON arrays are not supported. Use JSONL (newline-delimited JSON) instead")
+		if jsonPointer != "" {
+			return "json", nil
+		}
+		return detectJSON(filePath, compression, encoding)
 	}
 
 	// Try to detect from content (read first few bytes)
-	file, err := os.Open(filePath)
+	header, err := importer.PeekInput(filePath, compression, encoding, importer.SniffSize)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-
-	header := make([]byte, 512)
-	n, err := file.Read(header)
-	if err != nil && n == 0 {
+	if len(header) == 0 {
 		return "", fmt.Errorf("cannot read file to detect format")
 	}
+	n := len(header)
+
+	if compressed {
+		// Only text formats can be streamed through a decompressor
+		return importer.SniffFormat(header, n < importer.SniffSize), nil
+	}
 
 	// Check for XLSX magic bytes (ZIP signature)
 	if n >= 4 && header[0] == 0x50 && header[1] == 0x4B && header[2] == 0x03 && header[3] == 0x04 {
 		return "xlsx", nil
 	}
 
+	// Check for Parquet magic bytes
+	if n >= 4 && string(header[:4]) == "PAR1" {
+		return "parquet", nil
+	}
+
 	// Check for XLS magic bytes (OLE2 signature)
 	if n >= 8 && header[0] == 0xD0 && header[1] == 0xCF && header[2] == 0x11 && header[3] == 0xE0 {
 		return "", fmt.Errorf("XLS format detected (legacy Excel format). Please convert to XLSX or CSV")
 	}
 
-	// Default to CSV for text files
-	return "csv", nil
+	// Text: JSONL, a JSON document or delimited
+	return importer.SniffFormat(header, n < importer.SniffSize), nil
+}
+
+// detectJSON tells a JSON document from JSONL saved as .json: the first two
+// non-empty lines each holding a complete object mean JSONL. A single object
+// followed by a newline is a minified document. Only the start of the file
+// is read: a document that does not open with an object is json at once,
+// and a line still open after importer.MaxLineSize, longer than JSONL can
+// have, is json too.
+func detectJSON(filePath, compression, encoding string) (string, error) {
+	input, err := importer.OpenInput(filePath, compression, encoding)
+	if err != nil {
+		return "", err
+	}
+	defer input.Close()
+
+	limited := &io.LimitedReader{R: input, N: 2 * importer.MaxLineSize}
+	reader := bufio.NewReader(limited)
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return "json", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read file to detect format")
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		if b != '{' {
+			return "json", nil
+		}
+		reader.UnreadByte()
+		break
+	}
+
+	for objects := 0; objects < 2; {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("cannot read file to detect format")
+		}
+		if err == io.EOF && limited.N == 0 {
+			// Cut off at the limit
+			return "json", nil
+		}
+		if len(line) > importer.MaxLineSize {
+			return "json", nil
+		}
+
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) > 0 && line[0] == '{' && json.Valid(line):
+			objects++
+		case len(line) > 0:
+			return "json", nil
+		}
+		if err == io.EOF && objects < 2 {
+			return "json", nil
+		}
+	}
+	return "jsonl", nil
 }
 
 // contains checks if a string slice contains a value
@@ -3,29 +3,281 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// Insert methods used by BatchInsert
+const (
+	InsertMethodInsert     = "insert"      // Multi-value INSERT (all databases)
+	InsertMethodCopy       = "copy"        // PostgreSQL COPY FROM STDIN, text format
+	InsertMethodCopyBinary = "copy-binary" // PostgreSQL COPY FROM STDIN, binary format
+	InsertMethodLoadData   = "load-data"   // MySQL LOAD DATA LOCAL INFILE
+)
+
+// Conflict strategies used by BatchInsert when a row collides with an
+// existing primary or unique key
+const (
+	ConflictError          = "error"           // Fail the batch (plain INSERT)
+	ConflictSkip           = "skip"            // Keep the existing row
+	ConflictUpdateAll      = "update-all"      // Overwrite every non-key column
+	ConflictUpdateSelected = "update-selected" // Overwrite only UpdateColumns
+)
+
+// Load strategies decide what happens to the rows already in the target
+// table
+const (
+	LoadAppend      = "append"       // Keep existing rows
+	LoadTruncate    = "truncate"     // Remove every existing row first
+	LoadDeleteWhere = "delete-where" // Remove rows matching DeleteWhere first
+	LoadSwap        = "swap"         // Load a shadow copy and rename it over the table
+)
+
+// Options controls how a connector writes data
+type Options struct {
+	InsertMethod  string   // One of the InsertMethod* constants ("" = insert)
+	OnConflict    string   // One of the Conflict* constants ("" = error)
+	ConflictKeys  []string // Key columns that identify a conflicting row
+	UpdateColumns []string // Columns overwritten by ConflictUpdateSelected
+	LoadStrategy  string   // One of the Load* constants ("" = append)
+	DeleteWhere   string   // SQL predicate for LoadDeleteWhere
+}
+
+// Column kinds group database types by how values must be converted
+const (
+	KindText      = "text"
+	KindInteger   = "integer"
+	KindFloat     = "float"
+	KindNumeric   = "numeric"
+	KindBoolean   = "boolean"
+	KindDate      = "date"
+	KindTimestamp = "timestamp"
+	KindBinary    = "binary"
+	KindJSON      = "json"
+)
+
+// ErrTableNotFound is returned by DescribeTable when the table does not exist
+var ErrTableNotFound = errors.New("table not found")
+
+// ColumnInfo describes a table column
+type ColumnInfo struct {
+	Name     string
+	DataType string // Database type name as reported by information_schema
+	Kind     string // One of the Kind* constants
+	Nullable bool
+
+	// Digits before and after the decimal point, used when creating
+	// KindNumeric columns on databases that need an explicit precision
+	Precision int
+	Scale     int
+}
+
 // Connector is the interface for database operations
 type Connector interface {
 	BatchInsert(ctx context.Context, table string, columns []string, rows [][]interface{}) error
+	DescribeTable(ctx context.Context, table string) ([]ColumnInfo, error)
+	CreateTableSQL(table string, columns []ColumnInfo) string
+	CreateTable(ctx context.Context, table string, columns []ColumnInfo) error
+	CreateStagingTable(ctx context.Context, table, staging string) error
+	PublishStaging(ctx context.Context, staging, table string, columns []string) (int64, error)
+	DropTable(ctx context.Context, table string) error
+	ClearTable(ctx context.Context, table string) (int64, error)
+	CheckSwap(ctx context.Context, table string) ([]string, error)
+	SwapTables(ctx context.Context, table, shadow, retired string) error
 	StreamQuery(ctx context.Context, query string) (*sql.Rows, error)
 	GetColumns(rows *sql.Rows) ([]string, error)
 	Close() error
 }
 
 // NewConnector creates a new database connector based on DSN
-func NewConnector(dsn string) (Connector, error) {
+func NewConnector(dsn string, opts Options) (Connector, error) {
 	// Detect database type from DSN
 	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
-		return NewPostgresConnector(dsn)
+		return NewPostgresConnector(dsn, opts)
 	}
 	
 	// MySQL DSN formats: user:pass@tcp(host:port)/db or mysql://...
 	if strings.Contains(dsn, "@tcp(") || strings.HasPrefix(dsn, "mysql://") {
-		return NewMySQLConnector(dsn)
+		return NewMySQLConnector(dsn, opts)
 	}
 
 	return nil, fmt.Errorf("unsupported database type in DSN: %s", dsn)
 }
+
+// updateColumns returns the columns an upsert should overwrite
+func updateColumns(opts Options, columns []string) []string {
+	if opts.OnConflict == ConflictUpdateSelected {
+		return opts.UpdateColumns
+	}
+
+	keys := make(map[string]bool, len(opts.ConflictKeys))
+	for _, key := range opts.ConflictKeys {
+		keys[key] = true
+	}
+
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if !keys[col] {
+			updates = append(updates, col)
+		}
+	}
+	return updates
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// splitRows divides a batch into chunks that stay under maxParams bind
+// parameters and roughly maxBytes of statement payload. A single row that
+// exceeds maxBytes on its own still gets a chunk and is left to the server
+// to reject.
+func splitRows(rows [][]interface{}, columns, maxParams, maxBytes int) [][][]interface{} {
+	// Placeholders, separators and per-parameter protocol overhead
+	rowOverhead := columns * 16
+
+	chunks := make([][][]interface{}, 0, 1)
+	start, size := 0, 0
+	for i, row := range rows {
+		rowSize := rowOverhead
+		for _, val := range row {
+			rowSize += valueSize(val)
+		}
+
+		params := (i - start + 1) * columns
+		if i > start && (params > maxParams || size+rowSize > maxBytes) {
+			chunks = append(chunks, rows[start:i])
+			start, size = i, 0
+		}
+		size += rowSize
+	}
+	return append(chunks, rows[start:])
+}
+
+// valueSize estimates the encoded size of a bound value
+func valueSize(val interface{}) int {
+	switch v := val.(type) {
+	case nil:
+		return 4
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	default:
+		return 16
+	}
+}
+
+// execChunks inserts every chunk of a split batch. When there is more than
+// one chunk the statements share a transaction so the batch stays atomic.
+func execChunks(ctx context.Context, db *sql.DB, chunks [][][]interface{}, insert func(execer, [][]interface{}) error) error {
+	if len(chunks) == 1 {
+		return insert(db, chunks[0])
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("batch insert failed: begin transaction: %w", err)
+	}
+
+	for _, chunk := range chunks {
+		if err := insert(tx, chunk); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("batch insert failed: commit: %w", err)
+	}
+
+	return nil
+}
+
+// clearSQL returns the statement removing existing rows for the load
+// strategy, or "" when they are kept. truncate is the statement that
+// empties the whole table.
+func clearSQL(opts Options, table, truncate string) string {
+	switch opts.LoadStrategy {
+	case LoadTruncate:
+		return truncate
+	case LoadDeleteWhere:
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", table, opts.DeleteWhere)
+	default:
+		return ""
+	}
+}
+
+// clearTable runs a clearSQL statement and returns the rows it removed
+func clearTable(ctx context.Context, db *sql.DB, query, table string) (int64, error) {
+	if query == "" {
+		return 0, nil
+	}
+
+	result, err := db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear table %s: %w", table, err)
+	}
+
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
+
+// publish runs the INSERT ... SELECT that moves staged rows into the
+// target table inside a transaction, so the target sees all of them or
+// none. A non-empty clear statement runs first in the same transaction.
+func publish(ctx context.Context, db *sql.DB, clear, query, staging, table string) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish %s into %s: begin transaction: %w", staging, table, err)
+	}
+
+	if clear != "" {
+		if _, err := tx.ExecContext(ctx, clear); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to clear table %s: %w", table, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to publish %s into %s: %w", staging, table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to publish %s into %s: commit: %w", staging, table, err)
+	}
+
+	published, _ := result.RowsAffected()
+	return published, nil
+}
+
+// queryStrings runs a query returning one text column and collects it
+func queryStrings(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// IsRowError reports whether err was caused by the data in a row (a
+// constraint violation or a value the column cannot hold) rather than by
+// the connection or the statement itself. Batches failing with a row error
+// can be retried in smaller pieces to isolate the offending rows.
+func IsRowError(err error) bool {
+	return isPostgresRowError(err) || isMySQLRowError(err)
+}
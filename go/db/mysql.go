@@ -1,22 +1,52 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+)
+
+// loadDataSeq makes LOAD DATA reader handler names unique per batch
+var loadDataSeq uint64
+
+const (
+	// mysqlMaxParams is the limit on placeholders in a prepared statement
+	mysqlMaxParams = 65535
+
+	// mysqlDefaultPacket is used when max_allowed_packet cannot be read
+	mysqlDefaultPacket = 4 * 1024 * 1024
 )
 
 // MySQLConnector handles MySQL database operations
 type MySQLConnector struct {
-	db *sql.DB
+	db        *sql.DB
+	opts      Options
+	maxPacket int            // Effective max_allowed_packet for INSERT statements
+	loc       *time.Location // Zone of the DSN's loc parameter, used for LOAD DATA times
 }
 
 // NewMySQLConnector creates a new MySQL connector
-func NewMySQLConnector(dsn string) (*MySQLConnector, error) {
+func NewMySQLConnector(dsn string, opts Options) (*MySQLConnector, error) {
+	switch opts.InsertMethod {
+	case "", InsertMethodInsert, InsertMethodLoadData:
+	default:
+		return nil, fmt.Errorf("insert method %q is not supported for mysql", opts.InsertMethod)
+	}
+	if opts.InsertMethod == InsertMethodLoadData && (opts.OnConflict == ConflictUpdateAll || opts.OnConflict == ConflictUpdateSelected) {
+		return nil, fmt.Errorf("on_conflict %q requires the insert method, LOAD DATA can only skip duplicates", opts.OnConflict)
+	}
+
 	// Normalize DSN
 	// 1. Handle mysql:// scheme
 	if strings.HasPrefix(dsn, "mysql://") {
@@ -60,7 +90,7 @@ func NewMySQLConnector(dsn string) (*MySQLConnector, error) {
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
 
-	return &MySQLConnector{db: db}, nil
+	return &MySQLConnector{db: db, opts: opts, maxPacket: maxAllowedPacket(db, dsn), loc: dsnLocation(dsn)}, nil
 }
 
 // Close closes the database connection
@@ -68,12 +98,26 @@ func (m *MySQLConnector) Close() error {
 	return m.db.Close()
 }
 
-// BatchInsert performs a batch insert using multi-value INSERT
+// BatchInsert performs a batch insert using multi-value INSERT or LOAD DATA
 func (m *MySQLConnector) BatchInsert(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
 	if len(rows) == 0 {
 		return nil
 	}
 
+	if m.opts.InsertMethod == InsertMethodLoadData {
+		return m.loadData(ctx, table, columns, rows)
+	}
+
+	// Leave headroom for the statement text and packet framing
+	maxBytes := m.maxPacket - m.maxPacket/10
+	chunks := splitRows(rows, len(columns), mysqlMaxParams, maxBytes)
+	return execChunks(ctx, m.db, chunks, func(exec execer, chunk [][]interface{}) error {
+		return m.insertRows(ctx, exec, table, columns, chunk)
+	})
+}
+
+// insertRows executes a single multi-value INSERT statement
+func (m *MySQLConnector) insertRows(ctx context.Context, exec execer, table string, columns []string, rows [][]interface{}) error {
 	// Build column list
 	colList := strings.Join(columns, ", ")
 
@@ -91,9 +135,10 @@ func (m *MySQLConnector) BatchInsert(ctx context.Context, table string, columns
 	}
 
 	// Build and execute query
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, colList, strings.Join(valuePlaceholders, ", "))
+	verb, suffix := m.conflictClauses(columns)
+	query := fmt.Sprintf("%s INTO %s (%s) VALUES %s%s", verb, table, colList, strings.Join(valuePlaceholders, ", "), suffix)
 	
-	_, err := m.db.ExecContext(ctx, query, args...)
+	_, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("batch insert failed: %w", err)
 	}
@@ -101,6 +146,357 @@ func (m *MySQLConnector) BatchInsert(ctx context.Context, table string, columns
 	return nil
 }
 
+// dsnLocation returns the zone the driver formats time.Time parameters in:
+// the DSN's loc parameter, UTC by default
+func dsnLocation(dsn string) *time.Location {
+	if cfg, err := mysql.ParseDSN(dsn); err == nil && cfg.Loc != nil {
+		return cfg.Loc
+	}
+	return time.UTC
+}
+
+// maxAllowedPacket returns the smaller of the server's max_allowed_packet
+// and the client-side limit configured in the DSN
+func maxAllowedPacket(db *sql.DB, dsn string) int {
+	limit := mysqlDefaultPacket
+
+	var server int
+	if err := db.QueryRow("SELECT @@max_allowed_packet").Scan(&server); err == nil && server > 0 {
+		limit = server
+	}
+
+	if cfg, err := mysql.ParseDSN(dsn); err == nil && cfg.MaxAllowedPacket > 0 && cfg.MaxAllowedPacket < limit {
+		limit = cfg.MaxAllowedPacket
+	}
+
+	return limit
+}
+
+// conflictClauses returns the INSERT verb and the ON DUPLICATE KEY UPDATE
+// suffix for the configured conflict strategy. MySQL resolves conflicts on
+// any unique key, so ConflictKeys only narrows the columns left untouched.
+func (m *MySQLConnector) conflictClauses(columns []string) (string, string) {
+	switch m.opts.OnConflict {
+	case ConflictSkip:
+		return "INSERT IGNORE", ""
+	case ConflictUpdateAll, ConflictUpdateSelected:
+		updates := updateColumns(m.opts, columns)
+		if len(updates) == 0 {
+			// Every column is part of the key, nothing left to update
+			return "INSERT IGNORE", ""
+		}
+		sets := make([]string, len(updates))
+		for i, col := range updates {
+			sets[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+		}
+		return "INSERT", " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	default:
+		return "INSERT", ""
+	}
+}
+
+// loadData streams rows through LOAD DATA LOCAL INFILE using a registered
+// reader handler, so nothing is written to disk. The server runs LOCAL
+// loads as if IGNORE were given, turning duplicates and bad values into
+// warnings; unless duplicates are to be skipped, the batch is loaded in a
+// transaction and rolled back with the first warning as its error.
+func (m *MySQLConnector) loadData(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	var buf bytes.Buffer
+	for _, row := range rows {
+		for i, val := range row {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			if err := appendLoadDataText(&buf, val, m.loc); err != nil {
+				return fmt.Errorf("batch insert failed: LOAD DATA into %s (%d rows): %w", table, len(rows), err)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+
+	name := fmt.Sprintf("batch-%d", atomic.AddUint64(&loadDataSeq, 1))
+	mysql.RegisterReaderHandler(name, func() io.Reader { return &buf })
+	defer mysql.DeregisterReaderHandler(name)
+
+	ignore := ""
+	if m.opts.OnConflict == ConflictSkip {
+		ignore = "IGNORE "
+	}
+
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' %sINTO TABLE %s CHARACTER SET utf8mb4 "+
+		"FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (%s)",
+		name, ignore, table, strings.Join(columns, ", "))
+
+	if m.opts.OnConflict == ConflictSkip {
+		if _, err := m.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("batch insert failed: LOAD DATA into %s (%d rows): %w", table, len(rows), err)
+		}
+		return nil
+	}
+
+	// Warnings are per session, so the load and SHOW WARNINGS share the
+	// transaction's connection
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("batch insert failed: LOAD DATA into %s (%d rows): %w", table, len(rows), err)
+	}
+	result, err := tx.ExecContext(ctx, query)
+	if err == nil {
+		err = loadDataWarning(ctx, tx, result, len(rows))
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("batch insert failed: LOAD DATA into %s (%d rows): %w", table, len(rows), err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("batch insert failed: LOAD DATA into %s (%d rows): commit: %w", table, len(rows), err)
+	}
+
+	return nil
+}
+
+// loadDataWarning returns the first warning of a LOAD DATA statement as a
+// *mysql.MySQLError, so it reads as the row error INSERT would have raised,
+// or an error when fewer rows were loaded than sent
+func loadDataWarning(ctx context.Context, tx *sql.Tx, result sql.Result, sent int) error {
+	rows, err := tx.QueryContext(ctx, "SHOW WARNINGS")
+	if err != nil {
+		return fmt.Errorf("failed to read warnings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level, message string
+		var code uint16
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			return fmt.Errorf("failed to read warnings: %w", err)
+		}
+		if level != "Note" {
+			return &mysql.MySQLError{Number: code, Message: message}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read warnings: %w", err)
+	}
+
+	if loaded, err := result.RowsAffected(); err == nil && loaded < int64(sent) {
+		return fmt.Errorf("%d of %d rows loaded", loaded, sent)
+	}
+	return nil
+}
+
+// DescribeTable returns the columns of a table in ordinal order
+func (m *MySQLConnector) DescribeTable(ctx context.Context, table string) ([]ColumnInfo, error) {
+	name := strings.Trim(table, "`")
+	var schema interface{}
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		schema = strings.Trim(table[:i], "`")
+		name = strings.Trim(table[i+1:], "`")
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = COALESCE(?, DATABASE()) AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION`, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []ColumnInfo
+	for rows.Next() {
+		var col ColumnInfo
+		var columnType, nullable string
+		if err := rows.Scan(&col.Name, &col.DataType, &columnType, &nullable); err != nil {
+			return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+		}
+		col.Kind = mysqlKind(strings.ToLower(col.DataType), strings.ToLower(columnType))
+		col.Nullable = nullable == "YES"
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, table)
+	}
+
+	return columns, nil
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for the given columns
+func (m *MySQLConnector) CreateTableSQL(table string, columns []ColumnInfo) string {
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = fmt.Sprintf("  %s %s", col.Name, mysqlType(col))
+		if !col.Nullable {
+			defs[i] += " NOT NULL"
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", table, strings.Join(defs, ",\n"))
+}
+
+// CreateTable creates a table with the given columns
+func (m *MySQLConnector) CreateTable(ctx context.Context, table string, columns []ColumnInfo) error {
+	if _, err := m.db.ExecContext(ctx, m.CreateTableSQL(table, columns)); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	return nil
+}
+
+// CreateStagingTable creates an empty copy of table, including its keys
+// and defaults, to load into before publishing
+func (m *MySQLConnector) CreateStagingTable(ctx context.Context, table, staging string) error {
+	query := fmt.Sprintf("CREATE TABLE %s LIKE %s", staging, table)
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create staging table %s: %w", staging, err)
+	}
+	return nil
+}
+
+// PublishStaging copies every staged row into table in one transaction,
+// applying the configured conflict strategy, and returns the rows written
+func (m *MySQLConnector) PublishStaging(ctx context.Context, staging, table string, columns []string) (int64, error) {
+	colList := strings.Join(columns, ", ")
+	verb, suffix := m.conflictClauses(columns)
+	query := fmt.Sprintf("%s INTO %s (%s) SELECT %s FROM %s%s", verb, table, colList, colList, staging, suffix)
+
+	// TRUNCATE commits implicitly, so the transaction has to DELETE instead
+	clear := clearSQL(m.opts, table, fmt.Sprintf("DELETE FROM %s", table))
+	return publish(ctx, m.db, clear, query, staging, table)
+}
+
+// ClearTable removes the existing rows the load strategy replaces and
+// returns how many were deleted (TRUNCATE does not report a count)
+func (m *MySQLConnector) ClearTable(ctx context.Context, table string) (int64, error) {
+	query := clearSQL(m.opts, table, fmt.Sprintf("TRUNCATE TABLE %s", table))
+	return clearTable(ctx, m.db, query, table)
+}
+
+// CheckSwap refuses to swap a table that foreign keys of other tables refer
+// to: they follow the original table to its retired name, which then cannot
+// be dropped. It returns what the shadow table, a LIKE copy, does not carry
+// over from the table.
+func (m *MySQLConnector) CheckSwap(ctx context.Context, table string) ([]string, error) {
+	name := strings.Trim(table, "`")
+	var schema interface{}
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		schema = strings.Trim(table[:i], "`")
+		name = strings.Trim(table[i+1:], "`")
+	}
+
+	dependents, err := queryStrings(ctx, m.db, `SELECT CONCAT('foreign key ', CONSTRAINT_NAME, ' on ', CONSTRAINT_SCHEMA, '.', TABLE_NAME)
+		FROM information_schema.REFERENTIAL_CONSTRAINTS
+		WHERE UNIQUE_CONSTRAINT_SCHEMA = COALESCE(?, DATABASE()) AND REFERENCED_TABLE_NAME = ?
+			AND NOT (CONSTRAINT_SCHEMA = UNIQUE_CONSTRAINT_SCHEMA AND TABLE_NAME = REFERENCED_TABLE_NAME)`, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s for swap: %w", table, err)
+	}
+	if len(dependents) > 0 {
+		return nil, fmt.Errorf("load_strategy swap cannot replace %s, it is referenced by %s; use truncate with transactional instead",
+			table, strings.Join(dependents, ", "))
+	}
+
+	lost, err := queryStrings(ctx, m.db, `SELECT CONCAT('foreign key ', CONSTRAINT_NAME)
+		FROM information_schema.REFERENTIAL_CONSTRAINTS
+		WHERE CONSTRAINT_SCHEMA = COALESCE(?, DATABASE()) AND TABLE_NAME = ?
+		UNION ALL
+		SELECT CONCAT('trigger ', TRIGGER_NAME)
+		FROM information_schema.TRIGGERS
+		WHERE EVENT_OBJECT_SCHEMA = COALESCE(?, DATABASE()) AND EVENT_OBJECT_TABLE = ?`, schema, name, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s for swap: %w", table, err)
+	}
+	return lost, nil
+}
+
+// SwapTables replaces table with shadow using one atomic RENAME TABLE. The
+// original table is renamed to retired, which the caller drops afterwards.
+func (m *MySQLConnector) SwapTables(ctx context.Context, table, shadow, retired string) error {
+	query := fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", table, retired, shadow, table)
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to swap %s into %s: %w", shadow, table, err)
+	}
+	return nil
+}
+
+// DropTable drops a table if it exists
+func (m *MySQLConnector) DropTable(ctx context.Context, table string) error {
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table)); err != nil {
+		return fmt.Errorf("failed to drop table %s: %w", table, err)
+	}
+	return nil
+}
+
+// mysqlType maps a column kind onto a MySQL type
+func mysqlType(col ColumnInfo) string {
+	switch col.Kind {
+	case KindInteger:
+		return "BIGINT"
+	case KindFloat:
+		return "DOUBLE"
+	case KindNumeric:
+		// DECIMAL defaults to (10,0), so size it from the sampled values
+		scale := col.Scale
+		if scale > 30 {
+			scale = 30
+		}
+		precision := col.Precision
+		if precision < scale+1 {
+			precision = scale + 1
+		}
+		if precision > 65 {
+			precision = 65
+		}
+		return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale)
+	case KindBoolean:
+		return "BOOLEAN"
+	case KindDate:
+		return "DATE"
+	case KindTimestamp:
+		return "DATETIME(6)"
+	case KindBinary:
+		return "LONGBLOB"
+	case KindJSON:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+// mysqlKind maps a MySQL data type onto a column kind
+func mysqlKind(dataType, columnType string) string {
+	switch dataType {
+	case "tinyint":
+		// BOOL and BOOLEAN are aliases for TINYINT(1)
+		if columnType == "tinyint(1)" {
+			return KindBoolean
+		}
+		return KindInteger
+	case "smallint", "mediumint", "int", "integer", "bigint", "year":
+		return KindInteger
+	case "float", "double", "real":
+		return KindFloat
+	case "decimal", "numeric":
+		return KindNumeric
+	case "bit":
+		if columnType == "bit(1)" {
+			return KindBoolean
+		}
+		return KindInteger
+	case "date":
+		return KindDate
+	case "datetime", "timestamp":
+		return KindTimestamp
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return KindBinary
+	case "json":
+		return KindJSON
+	default:
+		return KindText
+	}
+}
+
 // StreamQuery executes a query and returns rows for streaming
 func (m *MySQLConnector) StreamQuery(ctx context.Context, query string) (*sql.Rows, error) {
 	rows, err := m.db.QueryContext(ctx, query)
@@ -115,3 +511,88 @@ func (m *MySQLConnector) StreamQuery(ctx context.Context, query string) (*sql.Ro
 func (m *MySQLConnector) GetColumns(rows *sql.Rows) ([]string, error) {
 	return rows.Columns()
 }
+
+// appendLoadDataText writes a value in the LOAD DATA format used by loadData.
+// nil becomes \N so NULLs match what the INSERT path binds, and times are
+// written in loc as the driver writes bound parameters.
+func appendLoadDataText(buf *bytes.Buffer, val interface{}, loc *time.Location) error {
+	var s string
+
+	switch v := val.(type) {
+	case nil:
+		buf.WriteString(`\N`)
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case bool:
+		if v {
+			s = "1"
+		} else {
+			s = "0"
+		}
+	case int:
+		s = strconv.FormatInt(int64(v), 10)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			s = "0000-00-00"
+		} else {
+			s = v.In(loc).Format("2006-01-02 15:04:05.999999")
+		}
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON value: %w", err)
+		}
+		s = string(data)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case 0:
+			buf.WriteString(`\0`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return nil
+}
+
+// mysqlRowErrors are server errors caused by the values of a single row
+var mysqlRowErrors = map[uint16]bool{
+	1048: true, // Column cannot be null
+	1062: true, // Duplicate entry
+	1264: true, // Out of range value
+	1265: true, // Data truncated
+	1292: true, // Incorrect date/time value
+	1364: true, // Field doesn't have a default value
+	1366: true, // Incorrect value for column
+	1406: true, // Data too long
+	1451: true, // Foreign key constraint fails (parent row)
+	1452: true, // Foreign key constraint fails (child row)
+	3140: true, // Invalid JSON text
+	3819: true, // Check constraint violated
+}
+
+// isMySQLRowError matches constraint and data conversion errors
+func isMySQLRowError(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	return mysqlRowErrors[myErr.Number]
+}
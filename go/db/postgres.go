@@ -1,21 +1,52 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lib/pq"
 )
 
-// PostgresConnector handles PostgreSQL database operations
+const (
+	// pgMaxParams is the protocol limit on bind parameters per statement
+	pgMaxParams = 65535
+
+	// pgMaxStatementBytes keeps a single INSERT well below the 1GB
+	// message size the server accepts
+	pgMaxStatementBytes = 256 * 1024 * 1024
+)
+
+// PostgresConnector handles PostgreSQL database operations. Statements go
+// through lib/pq; the COPY insert methods use pgx connections of their own.
 type PostgresConnector struct {
-	db *sql.DB
+	db   *sql.DB
+	copy *pgxpool.Pool // Only for the COPY insert methods
+	opts Options
 }
 
 // NewPostgresConnector creates a new PostgreSQL connector
-func NewPostgresConnector(dsn string) (*PostgresConnector, error) {
+func NewPostgresConnector(dsn string, opts Options) (*PostgresConnector, error) {
+	switch opts.InsertMethod {
+	case "", InsertMethodInsert, InsertMethodCopy, InsertMethodCopyBinary:
+	default:
+		return nil, fmt.Errorf("insert method %q is not supported for postgres", opts.InsertMethod)
+	}
+	if opts.OnConflict != "" && opts.OnConflict != ConflictError && opts.InsertMethod != "" && opts.InsertMethod != InsertMethodInsert {
+		return nil, fmt.Errorf("on_conflict %q requires the insert method, COPY cannot resolve conflicts", opts.OnConflict)
+	}
+
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
@@ -31,11 +62,41 @@ func NewPostgresConnector(dsn string) (*PostgresConnector, error) {
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
 
-	return &PostgresConnector{db: db}, nil
+	p := &PostgresConnector{db: db, opts: opts}
+	if opts.InsertMethod == InsertMethodCopy || opts.InsertMethod == InsertMethodCopyBinary {
+		if p.copy, err = openCopyPool(dsn); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return p, nil
 }
 
-// Close closes the database connection
+// openCopyPool opens the pgx connections used for COPY. pgx reads the same
+// DSN forms as lib/pq, with libpq's defaults.
+func openCopyPool(dsn string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres connection for COPY: %w", err)
+	}
+	config.MaxConns = 25
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres connection for COPY: %w", err)
+	}
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping postgres for COPY: %w", err)
+	}
+	return pool, nil
+}
+
+// Close closes the database connections
 func (p *PostgresConnector) Close() error {
+	if p.copy != nil {
+		p.copy.Close()
+	}
 	return p.db.Close()
 }
 
@@ -45,24 +106,176 @@ func (p *PostgresConnector) BatchInsert(ctx context.Context, table string, colum
 		return nil
 	}
 
-	// Use multi-value INSERT for better compatibility
-	// COPY would be faster but requires more complex setup
-	return p.multiValueInsert(ctx, table, columns, rows)
+	switch p.opts.InsertMethod {
+	case InsertMethodCopy:
+		return p.copyText(ctx, table, columns, rows)
+	case InsertMethodCopyBinary:
+		return p.copyBinary(ctx, table, columns, rows)
+	default:
+		// Multi-value INSERT is the most compatible path
+		return p.multiValueInsert(ctx, table, columns, rows)
+	}
+}
+
+// copyText streams rows through COPY FROM STDIN using the text format
+func (p *PostgresConnector) copyText(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	// Encode the whole batch up front; it is already held in memory
+	var buf bytes.Buffer
+	for _, row := range rows {
+		for i, val := range row {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			if err := appendCopyText(&buf, val); err != nil {
+				return fmt.Errorf("batch insert failed: COPY into %s (%d rows): %w", table, len(rows), err)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", table, strings.Join(columns, ", "))
+
+	err := p.withConn(ctx, func(conn *pgx.Conn) error {
+		_, err := conn.PgConn().CopyFrom(ctx, &buf, query)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("batch insert failed: COPY into %s (%d rows): %w", table, len(rows), err)
+	}
+
+	return nil
 }
 
-// multiValueInsert performs a multi-value INSERT statement
+// copyBinary streams rows through COPY FROM STDIN using the binary format
+func (p *PostgresConnector) copyBinary(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	// pgx quotes identifiers, so fold them the same way the server folds
+	// the unquoted names used by the INSERT path
+	columnNames := make([]string, len(columns))
+	for i, col := range columns {
+		columnNames[i] = foldIdentifier(col)
+	}
+
+	err := p.withConn(ctx, func(conn *pgx.Conn) error {
+		types, err := columnTypes(ctx, conn, table, columnNames)
+		if err != nil {
+			return err
+		}
+		values, err := binaryRows(conn.TypeMap(), types, columnNames, rows)
+		if err != nil {
+			return err
+		}
+		_, err = conn.CopyFrom(ctx, copyTableIdentifier(table), columnNames, pgx.CopyFromRows(values))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("batch insert failed: binary COPY into %s (%d rows): %w", table, len(rows), err)
+	}
+
+	return nil
+}
+
+// pgMoneyOID is the type of money columns, which pgx has no binary codec for
+const pgMoneyOID = 790
+
+// columnTypes returns the type OIDs of columns of table, as binary COPY
+// has to encode each value for its column type
+func columnTypes(ctx context.Context, conn *pgx.Conn, table string, columns []string) ([]uint32, error) {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = pgx.Identifier{col}.Sanitize()
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), copyTableIdentifier(table).Sanitize())
+	desc, err := conn.PgConn().Prepare(ctx, "", query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe columns: %w", err)
+	}
+
+	types := make([]uint32, len(desc.Fields))
+	for i, field := range desc.Fields {
+		if field.DataTypeOID == pgMoneyOID {
+			return nil, fmt.Errorf("binary COPY cannot write money column %s; use insert_method copy", columns[i])
+		}
+		types[i] = field.DataTypeOID
+	}
+	return types, nil
+}
+
+// binaryRows converts the values of rows so they can be encoded in binary
+// for their column types. Coercion leaves numeric, uuid, inet and similar
+// values as text, which the binary encoders do not take; those strings are
+// parsed the way the server parses text input. A value that does not parse
+// fails with SQLSTATE 22P02 so the batch is bisected down to its row.
+func binaryRows(m *pgtype.Map, types []uint32, columns []string, rows [][]interface{}) ([][]interface{}, error) {
+	// Columns whose type cannot encode a string directly
+	parse := make([]*pgtype.Type, len(types))
+	for i, oid := range types {
+		if m.PlanEncode(oid, pgtype.BinaryFormatCode, "") == nil {
+			if typ, ok := m.TypeForOID(oid); ok {
+				parse[i] = typ
+			}
+		}
+	}
+
+	out := make([][]interface{}, len(rows))
+	for r, row := range rows {
+		// Rows are shared with bisection, so changed ones are copied
+		converted, copied := row, false
+		for i, val := range row {
+			s, ok := val.(string)
+			if !ok || i >= len(parse) || parse[i] == nil {
+				continue
+			}
+			value, err := parse[i].Codec.DecodeValue(m, types[i], pgtype.TextFormatCode, []byte(s))
+			if err != nil {
+				return nil, &pgconn.PgError{
+					Severity: "ERROR",
+					Code:     "22P02", // invalid_text_representation
+					Message:  fmt.Sprintf("invalid input for type %s in column %s: %q: %v", parse[i].Name, columns[i], s, err),
+				}
+			}
+			if !copied {
+				converted, copied = append([]interface{}(nil), row...), true
+			}
+			converted[i] = value
+		}
+		out[r] = converted
+	}
+	return out, nil
+}
+
+// withConn runs fn on a pgx connection taken from the COPY pool
+func (p *PostgresConnector) withConn(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := p.copy.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	return fn(conn.Conn())
+}
+
+// multiValueInsert performs multi-value INSERT statements, splitting the
+// batch when it would exceed the parameter or statement size limits
 func (p *PostgresConnector) multiValueInsert(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
 	if len(rows) == 0 {
 		return nil
 	}
 
+	chunks := splitRows(rows, len(columns), pgMaxParams, pgMaxStatementBytes)
+	return execChunks(ctx, p.db, chunks, func(exec execer, chunk [][]interface{}) error {
+		return p.insertRows(ctx, exec, table, columns, chunk)
+	})
+}
+
+// insertRows executes a single multi-value INSERT statement
+func (p *PostgresConnector) insertRows(ctx context.Context, exec execer, table string, columns []string, rows [][]interface{}) error {
 	// Build column list
 	colList := strings.Join(columns, ", ")
 
 	// Build value placeholders
 	valuePlaceholders := make([]string, len(rows))
 	args := make([]interface{}, 0, len(rows)*len(columns))
-	
+
 	for i, row := range rows {
 		placeholders := make([]string, len(columns))
 		for j := range columns {
@@ -73,9 +286,10 @@ func (p *PostgresConnector) multiValueInsert(ctx context.Context, table string,
 	}
 
 	// Build and execute query
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, colList, strings.Join(valuePlaceholders, ", "))
-	
-	_, err := p.db.ExecContext(ctx, query, args...)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s%s", table, colList, strings.Join(valuePlaceholders, ", "),
+		p.conflictClause(columns))
+
+	_, err := exec.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("batch insert failed: %w", err)
 	}
@@ -83,6 +297,280 @@ func (p *PostgresConnector) multiValueInsert(ctx context.Context, table string,
 	return nil
 }
 
+// conflictClause builds the ON CONFLICT clause for the configured strategy
+func (p *PostgresConnector) conflictClause(columns []string) string {
+	target := ""
+	if len(p.opts.ConflictKeys) > 0 {
+		target = fmt.Sprintf(" (%s)", strings.Join(p.opts.ConflictKeys, ", "))
+	}
+
+	switch p.opts.OnConflict {
+	case ConflictSkip:
+		return fmt.Sprintf(" ON CONFLICT%s DO NOTHING", target)
+	case ConflictUpdateAll, ConflictUpdateSelected:
+		updates := updateColumns(p.opts, columns)
+		if len(updates) == 0 {
+			// Every column is part of the key, nothing left to update
+			return fmt.Sprintf(" ON CONFLICT%s DO NOTHING", target)
+		}
+		sets := make([]string, len(updates))
+		for i, col := range updates {
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+		}
+		return fmt.Sprintf(" ON CONFLICT%s DO UPDATE SET %s", target, strings.Join(sets, ", "))
+	default:
+		return ""
+	}
+}
+
+// DescribeTable returns the columns of a table in ordinal order. The table
+// is resolved as the server resolves it in a statement, through every
+// schema of search_path.
+func (p *PostgresConnector) DescribeTable(ctx context.Context, table string) ([]ColumnInfo, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT c.column_name, c.data_type, c.udt_name, c.is_nullable
+		FROM information_schema.columns c
+		JOIN pg_class t ON t.relname = c.table_name
+		JOIN pg_namespace n ON n.oid = t.relnamespace AND n.nspname = c.table_schema
+		WHERE t.oid = to_regclass($1::text)
+		ORDER BY c.ordinal_position`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []ColumnInfo
+	for rows.Next() {
+		var col ColumnInfo
+		var udtName, nullable string
+		if err := rows.Scan(&col.Name, &col.DataType, &udtName, &nullable); err != nil {
+			return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+		}
+		if col.DataType == "USER-DEFINED" || col.DataType == "ARRAY" {
+			col.DataType = udtName
+		}
+		col.Kind = postgresKind(udtName)
+		col.Nullable = nullable == "YES"
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, table)
+	}
+
+	return columns, nil
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for the given columns
+func (p *PostgresConnector) CreateTableSQL(table string, columns []ColumnInfo) string {
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = fmt.Sprintf("  %s %s", col.Name, postgresType(col))
+		if !col.Nullable {
+			defs[i] += " NOT NULL"
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", table, strings.Join(defs, ",\n"))
+}
+
+// CreateTable creates a table with the given columns
+func (p *PostgresConnector) CreateTable(ctx context.Context, table string, columns []ColumnInfo) error {
+	if _, err := p.db.ExecContext(ctx, p.CreateTableSQL(table, columns)); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	return nil
+}
+
+// CreateStagingTable creates an empty copy of table, including its keys
+// and defaults, to load into before publishing. It is unlogged since the
+// rows are only published once they are all loaded.
+func (p *PostgresConnector) CreateStagingTable(ctx context.Context, table, staging string) error {
+	query := fmt.Sprintf("CREATE UNLOGGED TABLE %s (LIKE %s INCLUDING ALL)", staging, table)
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create staging table %s: %w", staging, err)
+	}
+	return nil
+}
+
+// PublishStaging copies every staged row into table in one transaction,
+// applying the configured load and conflict strategies, and returns the
+// rows written
+func (p *PostgresConnector) PublishStaging(ctx context.Context, staging, table string, columns []string) (int64, error) {
+	colList := strings.Join(columns, ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s%s", table, colList, colList, staging,
+		p.conflictClause(columns))
+
+	return publish(ctx, p.db, p.clearSQL(table), query, staging, table)
+}
+
+// ClearTable removes the existing rows the load strategy replaces and
+// returns how many were deleted (TRUNCATE does not report a count)
+func (p *PostgresConnector) ClearTable(ctx context.Context, table string) (int64, error) {
+	return clearTable(ctx, p.db, p.clearSQL(table), table)
+}
+
+// clearSQL returns the statement removing rows for the load strategy.
+// TRUNCATE is transactional in PostgreSQL, so it also works on publish.
+func (p *PostgresConnector) clearSQL(table string) string {
+	return clearSQL(p.opts, table, fmt.Sprintf("TRUNCATE TABLE %s", table))
+}
+
+// CheckSwap refuses to swap a table that foreign keys of other tables or
+// views refer to: they follow the original table to its retired name, which
+// then cannot be dropped. It returns what the shadow table, a LIKE copy,
+// does not carry over from the table.
+func (p *PostgresConnector) CheckSwap(ctx context.Context, table string) ([]string, error) {
+	dependents, err := queryStrings(ctx, p.db, `
+		SELECT format('foreign key %s on %s', conname, conrelid::regclass)
+		FROM pg_constraint
+		WHERE contype = 'f' AND confrelid = $1::text::regclass AND conrelid <> confrelid
+		UNION ALL
+		SELECT DISTINCT format('view %s', r.ev_class::regclass)
+		FROM pg_depend d JOIN pg_rewrite r ON r.oid = d.objid
+		WHERE d.classid = 'pg_rewrite'::regclass AND d.refobjid = $1::text::regclass AND r.ev_class <> $1::text::regclass`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s for swap: %w", table, err)
+	}
+	if len(dependents) > 0 {
+		return nil, fmt.Errorf("load_strategy swap cannot replace %s, it is referenced by %s; use truncate with transactional instead",
+			table, strings.Join(dependents, ", "))
+	}
+
+	lost, err := queryStrings(ctx, p.db, `
+		SELECT format('foreign key %s', conname) FROM pg_constraint WHERE contype = 'f' AND conrelid = $1::text::regclass
+		UNION ALL
+		SELECT format('trigger %s', tgname) FROM pg_trigger WHERE tgrelid = $1::text::regclass AND NOT tgisinternal
+		UNION ALL
+		SELECT format('policy %s', polname) FROM pg_policy WHERE polrelid = $1::text::regclass
+		UNION ALL
+		SELECT 'grants' FROM pg_class WHERE oid = $1::text::regclass AND relacl IS NOT NULL`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s for swap: %w", table, err)
+	}
+	return lost, nil
+}
+
+// SwapTables replaces table with shadow in one transaction. The original
+// table is renamed to retired, which the caller drops afterwards. Sequences
+// of serial columns, owned by table and shared by the shadow's defaults,
+// are handed over to the shadow so the retired table can be dropped.
+func (p *PostgresConnector) SwapTables(ctx context.Context, table, shadow, retired string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to swap %s into %s: begin transaction: %w", shadow, table, err)
+	}
+
+	owned, err := ownedSequences(ctx, tx, table)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to swap %s into %s: %w", shadow, table, err)
+	}
+
+	// RENAME TO takes a bare name; the table stays in its schema
+	statements := make([]string, 0, len(owned)+3)
+	for _, seq := range owned {
+		statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s", seq[0], shadow, pgx.Identifier{seq[1]}.Sanitize()))
+	}
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s SET LOGGED", shadow),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", table, unqualifiedName(retired)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", shadow, unqualifiedName(table)),
+	)
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to swap %s into %s: %w", shadow, table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to swap %s into %s: commit: %w", shadow, table, err)
+	}
+	return nil
+}
+
+// ownedSequences returns the sequences owned by serial columns of table, as
+// pairs of sequence name and column name
+func ownedSequences(ctx context.Context, tx *sql.Tx, table string) ([][2]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT d.objid::regclass::text, a.attname
+		FROM pg_depend d
+		JOIN pg_class s ON s.oid = d.objid AND s.relkind = 'S'
+		JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+		WHERE d.classid = 'pg_class'::regclass AND d.refobjid = $1::text::regclass AND d.deptype = 'a'`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list owned sequences: %w", err)
+	}
+	defer rows.Close()
+
+	var owned [][2]string
+	for rows.Next() {
+		var seq [2]string
+		if err := rows.Scan(&seq[0], &seq[1]); err != nil {
+			return nil, fmt.Errorf("failed to list owned sequences: %w", err)
+		}
+		owned = append(owned, seq)
+	}
+	return owned, rows.Err()
+}
+
+// DropTable drops a table if it exists
+func (p *PostgresConnector) DropTable(ctx context.Context, table string) error {
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table)); err != nil {
+		return fmt.Errorf("failed to drop table %s: %w", table, err)
+	}
+	return nil
+}
+
+// postgresType maps a column kind onto a PostgreSQL type
+func postgresType(col ColumnInfo) string {
+	switch col.Kind {
+	case KindInteger:
+		return "BIGINT"
+	case KindFloat:
+		return "DOUBLE PRECISION"
+	case KindNumeric:
+		return "NUMERIC"
+	case KindBoolean:
+		return "BOOLEAN"
+	case KindDate:
+		return "DATE"
+	case KindTimestamp:
+		return "TIMESTAMP"
+	case KindBinary:
+		return "BYTEA"
+	case KindJSON:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+// postgresKind maps a PostgreSQL udt_name onto a column kind
+func postgresKind(udtName string) string {
+	switch udtName {
+	case "int2", "int4", "int8":
+		return KindInteger
+	case "float4", "float8":
+		return KindFloat
+	case "numeric", "money":
+		return KindNumeric
+	case "bool":
+		return KindBoolean
+	case "date":
+		return KindDate
+	case "timestamp", "timestamptz":
+		return KindTimestamp
+	case "bytea":
+		return KindBinary
+	case "json", "jsonb":
+		return KindJSON
+	default:
+		return KindText
+	}
+}
+
 // StreamQuery executes a query and returns rows for streaming
 func (p *PostgresConnector) StreamQuery(ctx context.Context, query string) (*sql.Rows, error) {
 	// Use a transaction with a cursor for large result sets
@@ -110,3 +598,97 @@ func (p *PostgresConnector) StreamQuery(ctx context.Context, query string) (*sql
 func (p *PostgresConnector) GetColumns(rows *sql.Rows) ([]string, error) {
 	return rows.Columns()
 }
+
+// appendCopyText writes a value in COPY text format
+func appendCopyText(buf *bytes.Buffer, val interface{}) error {
+	var s string
+
+	switch v := val.(type) {
+	case nil:
+		buf.WriteString(`\N`)
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = `\x` + hex.EncodeToString(v)
+	case bool:
+		s = strconv.FormatBool(v)
+	case int:
+		s = strconv.FormatInt(int64(v), 10)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON value: %w", err)
+		}
+		s = string(data)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	// Backslash, tab and line breaks are special in COPY text format
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return nil
+}
+
+// copyTableIdentifier splits a possibly schema-qualified table name for pgx
+func copyTableIdentifier(table string) pgx.Identifier {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = foldIdentifier(part)
+	}
+	return pgx.Identifier(parts)
+}
+
+// unqualifiedName strips the schema from a table name
+func unqualifiedName(table string) string {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[i+1:]
+	}
+	return table
+}
+
+// foldIdentifier mimics PostgreSQL identifier folding: quoted names are
+// used verbatim, unquoted names are lower-cased
+func foldIdentifier(name string) string {
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	}
+	return strings.ToLower(name)
+}
+
+// isPostgresRowError matches data exceptions (SQLSTATE class 22) and
+// integrity constraint violations (class 23), from lib/pq or from pgx
+func isPostgresRowError(err error) bool {
+	var code string
+	var pqErr *pq.Error
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pqErr):
+		code = string(pqErr.Code)
+	case errors.As(err, &pgErr):
+		code = pgErr.Code
+	default:
+		return false
+	}
+	return strings.HasPrefix(code, "22") || strings.HasPrefix(code, "23")
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// testPostgres connects to the database in DATA_ENGINE_TEST_POSTGRES, or
+// skips the test when it is not set
+func testPostgres(t *testing.T) *PostgresConnector {
+	t.Helper()
+	dsn := os.Getenv("DATA_ENGINE_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("DATA_ENGINE_TEST_POSTGRES is not set")
+	}
+	p, err := NewPostgresConnector(dsn, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	// One connection, so session settings apply to every query
+	p.db.SetMaxOpenConns(1)
+	return p
+}
+
+func TestDescribeTableSearchPath(t *testing.T) {
+	p := testPostgres(t)
+	ctx := context.Background()
+
+	for _, stmt := range []string{
+		"DROP SCHEMA IF EXISTS data_engine_test_first CASCADE",
+		"DROP SCHEMA IF EXISTS data_engine_test_second CASCADE",
+		"CREATE SCHEMA data_engine_test_first",
+		"CREATE SCHEMA data_engine_test_second",
+		"CREATE TABLE data_engine_test_second.items (id integer NOT NULL, amount numeric(10,2), code uuid)",
+		"SET search_path TO data_engine_test_first, data_engine_test_second",
+	} {
+		if _, err := p.db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	t.Cleanup(func() {
+		p.db.ExecContext(ctx, "DROP SCHEMA data_engine_test_first, data_engine_test_second CASCADE")
+	})
+
+	// items is not in current_schema(), only later in search_path
+	columns, err := p.DescribeTable(ctx, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, col := range columns {
+		names = append(names, col.Name)
+	}
+	if len(names) != 3 || names[0] != "id" || names[1] != "amount" || names[2] != "code" {
+		t.Fatalf("columns = %v, want [id amount code]", names)
+	}
+	if columns[0].Nullable || !columns[1].Nullable {
+		t.Errorf("nullability = %v, %v, want false, true", columns[0].Nullable, columns[1].Nullable)
+	}
+
+	// A table of the same name earlier in search_path is the one used
+	if _, err := p.db.ExecContext(ctx, "CREATE TABLE data_engine_test_first.items (name text)"); err != nil {
+		t.Fatal(err)
+	}
+	columns, err = p.DescribeTable(ctx, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 1 || columns[0].Name != "name" {
+		t.Errorf("columns = %+v, want the table in data_engine_test_first", columns)
+	}
+
+	if _, err := p.DescribeTable(ctx, "data_engine_test_second.missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("missing table: got %v, want ErrTableNotFound", err)
+	}
+}
+
+func TestBinaryRows(t *testing.T) {
+	m := pgtype.NewMap()
+	columns := []string{"amount", "id", "name", "qty", "day", "ok", "addr", "note"}
+	types := []uint32{pgtype.NumericOID, pgtype.UUIDOID, pgtype.TextOID, pgtype.Int4OID, pgtype.DateOID, pgtype.BoolOID, pgtype.InetOID, pgtype.TextOID}
+	row := []interface{}{"12345.678901234567890123", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "text", "42", "2024-02-29", "true", "192.168.0.1/24", nil}
+	want := []string{"12345.678901234567890123", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "text", "42", "2024-02-29", "true", "192.168.0.1/24", ""}
+
+	// Strings cannot be encoded in binary for these types as they are
+	for _, i := range []int{0, 1, 3} {
+		if _, err := m.Encode(types[i], pgtype.BinaryFormatCode, row[i], nil); err == nil {
+			t.Fatalf("%s: expected encoding a string to fail", columns[i])
+		}
+	}
+
+	rows, err := binaryRows(m, types, columns, [][]interface{}{row})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[0] != "12345.678901234567890123" {
+		t.Errorf("input row was modified: %v", row)
+	}
+
+	for i, val := range rows[0] {
+		buf, err := m.Encode(types[i], pgtype.BinaryFormatCode, val, nil)
+		if err != nil {
+			t.Errorf("%s: binary encode: %v", columns[i], err)
+			continue
+		}
+		if buf == nil {
+			if want[i] != "" {
+				t.Errorf("%s: encoded as NULL", columns[i])
+			}
+			continue
+		}
+		// Decode the binary value and print it as text to compare
+		typ, ok := m.TypeForOID(types[i])
+		if !ok {
+			t.Fatalf("%s: unknown type", columns[i])
+		}
+		value, err := typ.Codec.DecodeValue(m, types[i], pgtype.BinaryFormatCode, buf)
+		if err != nil {
+			t.Errorf("%s: decode: %v", columns[i], err)
+			continue
+		}
+		text, err := m.Encode(types[i], pgtype.TextFormatCode, value, nil)
+		if err != nil {
+			t.Errorf("%s: text encode: %v", columns[i], err)
+			continue
+		}
+		if string(text) != want[i] && !(columns[i] == "ok" && string(text) == "t") {
+			t.Errorf("%s: round trip gave %q, want %q", columns[i], text, want[i])
+		}
+	}
+}
+
+func TestBinaryRowsInvalidValue(t *testing.T) {
+	m := pgtype.NewMap()
+	types := []uint32{pgtype.NumericOID, pgtype.UUIDOID}
+	columns := []string{"amount", "id"}
+
+	for _, row := range [][]interface{}{
+		{"12.5x", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{"12.5", "not-a-uuid"},
+	} {
+		_, err := binaryRows(m, types, columns, [][]interface{}{row})
+		if err == nil {
+			t.Errorf("%v: expected an error", row)
+			continue
+		}
+		if !IsRowError(err) {
+			t.Errorf("%v: %v is not a row error, so it would not be bisected", row, err)
+		}
+	}
+}
@@ -1,44 +1,69 @@
 package exporter
 
 import (
-	"encoding/csv"
 	"fmt"
-	"os"
+	"io"
+
+	"github.com/datamill/data-engine/go/textfile"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 // CSVExporter handles CSV and TSV file exports
 type CSVExporter struct {
-	filePath  string
-	delimiter rune
-	columns   []string
-	file      *os.File
-	writer    *csv.Writer
+	filePath string
+	dialect  CSVDialect
+	encoding string
+	columns  []string
+	compress CompressionOptions
+	out      *output
+	encoder  io.WriteCloser // nil for UTF-8
+	writer   recordWriter
 }
 
-// NewCSVExporter creates a new CSV exporter
-func NewCSVExporter(filePath string, delimiter rune, columns []string) *CSVExporter {
+// NewCSVExporter creates a new CSV exporter. encoding is a name known to
+// textfile.LookupEncoding ("" = UTF-8).
+func NewCSVExporter(filePath string, dialect CSVDialect, encoding string, columns []string, compress CompressionOptions) *CSVExporter {
 	return &CSVExporter{
-		filePath:  filePath,
-		delimiter: delimiter,
-		columns:   columns,
+		filePath: filePath,
+		dialect:  dialect,
+		encoding: encoding,
+		columns:  columns,
+		compress: compress,
 	}
 }
 
 // Open opens the CSV file and writes the header
 func (c *CSVExporter) Open() error {
-	file, err := os.Create(c.filePath)
+	var enc encoding.Encoding
+	if c.encoding != "" {
+		var err error
+		if enc, err = textfile.LookupEncoding(c.encoding); err != nil {
+			return err
+		}
+	}
+
+	out, err := createOutput(c.filePath, c.compress)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return err
 	}
-	c.file = file
+	c.out = out
 
-	// Create CSV writer
-	c.writer = csv.NewWriter(file)
-	c.writer.Comma = c.delimiter
+	// Create CSV writer, encoding its output unless it stays UTF-8
+	var w io.Writer = out
+	if enc != nil && enc != unicode.UTF8 {
+		c.encoder = transform.NewWriter(out, enc.NewEncoder())
+		w = c.encoder
+	}
+	c.writer = newRecordWriter(w, c.dialect)
 
 	// Write header
+	if c.dialect.NoHeader {
+		return nil
+	}
 	if err := c.writer.Write(c.columns); err != nil {
-		c.file.Close()
+		c.out.Close()
 		return fmt.Errorf("failed to write header: %w", err)
 	}
 
@@ -63,16 +88,27 @@ func (c *CSVExporter) WriteRow(row []interface{}) error {
 // Flush flushes the CSV writer
 func (c *CSVExporter) Flush() error {
 	c.writer.Flush()
-	return c.writer.Error()
+	err := c.writer.Error()
+	if err != nil && c.encoder != nil {
+		return fmt.Errorf("failed to write %s output, a value may hold a character it cannot represent: %w", c.encoding, err)
+	}
+	return err
 }
 
-// Close closes the CSV file
+// Close closes the CSV file, finishing the compressed stream if any
 func (c *CSVExporter) Close() error {
 	if c.writer != nil {
 		c.writer.Flush()
 	}
-	if c.file != nil {
-		return c.file.Close()
+	if c.encoder != nil {
+		if err := c.encoder.Close(); err != nil {
+			c.out.Close()
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		c.encoder = nil
+	}
+	if c.out != nil {
+		return c.out.Close()
 	}
 	return nil
 }
@@ -0,0 +1,141 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/datamill/data-engine/go/textfile"
+)
+
+// CSVDialect describes how a delimited file is written
+type CSVDialect struct {
+	Delimiter rune   // Field separator
+	Quote     rune   // Quote character (0 = never quote)
+	Escape    string // One of the textfile.Escape* constants ("" = double)
+	NoHeader  bool   // Leave out the header row
+	QuoteAll  bool   // Quote every field, not only those that need it
+	CRLF      bool   // End lines with \r\n
+}
+
+// recordWriter writes one CSV record at a time. csv.Writer is one.
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// newRecordWriter returns a writer for the dialect, using encoding/csv
+// whenever it can
+func newRecordWriter(w io.Writer, d CSVDialect) recordWriter {
+	if d.Quote != '"' || d.Escape == textfile.EscapeBackslash || d.QuoteAll {
+		return &dialectWriter{w: bufio.NewWriterSize(w, 64*1024), dialect: d}
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = d.Delimiter
+	writer.UseCRLF = d.CRLF
+	return writer
+}
+
+// dialectWriter writes the dialects encoding/csv does not: a quote
+// character other than the double quote, no quoting, backslash escapes or
+// every field quoted
+type dialectWriter struct {
+	w       *bufio.Writer
+	dialect CSVDialect
+}
+
+// Write writes one record
+func (w *dialectWriter) Write(record []string) error {
+	if w.dialect.Quote == 0 && w.dialect.Escape != textfile.EscapeBackslash {
+		for _, field := range record {
+			if strings.ContainsRune(field, w.dialect.Delimiter) || strings.ContainsAny(field, "\r\n") {
+				return fmt.Errorf("field %q holds the delimiter or a line break and cannot be written unquoted", field)
+			}
+		}
+	}
+
+	for i, field := range record {
+		if i > 0 {
+			w.w.WriteRune(w.dialect.Delimiter)
+		}
+		w.writeField(field)
+	}
+	var err error
+	if w.dialect.CRLF {
+		_, err = w.w.WriteString("\r\n")
+	} else {
+		err = w.w.WriteByte('\n')
+	}
+	return err
+}
+
+// writeField writes a field, quoted when it has to be or QuoteAll asks.
+// Write errors are kept by the buffered writer and returned by Error.
+func (w *dialectWriter) writeField(field string) {
+	d := w.dialect
+	backslash := d.Escape == textfile.EscapeBackslash
+
+	if d.Quote == 0 {
+		if !backslash {
+			w.w.WriteString(field)
+			return
+		}
+		for _, ch := range field {
+			if ch == d.Delimiter || ch == '\\' || ch == '\r' || ch == '\n' {
+				w.w.WriteByte('\\')
+			}
+			w.w.WriteRune(ch)
+		}
+		return
+	}
+
+	if !d.QuoteAll && !w.needsQuotes(field) {
+		w.w.WriteString(field)
+		return
+	}
+	w.w.WriteRune(d.Quote)
+	for _, ch := range field {
+		switch {
+		case ch == d.Quote && backslash:
+			w.w.WriteByte('\\')
+		case ch == d.Quote:
+			w.w.WriteRune(d.Quote)
+		case ch == '\\' && backslash:
+			w.w.WriteByte('\\')
+		}
+		w.w.WriteRune(ch)
+	}
+	w.w.WriteRune(d.Quote)
+}
+
+// needsQuotes reports whether a field must be quoted to read back the same,
+// following encoding/csv
+func (w *dialectWriter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, w.dialect.Delimiter) || strings.ContainsRune(field, w.dialect.Quote) ||
+		strings.ContainsAny(field, "\r\n") {
+		return true
+	}
+	if w.dialect.Escape == textfile.EscapeBackslash && strings.ContainsRune(field, '\\') {
+		return true
+	}
+	ch, _ := utf8.DecodeRuneInString(field)
+	return ch == ' ' || ch == '\t'
+}
+
+// Flush writes buffered records to the output
+func (w *dialectWriter) Flush() {
+	w.w.Flush()
+}
+
+// Error returns the first error from a write or flush
+func (w *dialectWriter) Error() error {
+	_, err := w.w.Write(nil)
+	return err
+}
@@ -9,12 +9,13 @@ import (
 	"time"
 
 	"github.com/datamill/data-engine/go/db"
+	"github.com/datamill/data-engine/go/textfile"
 )
 
 // ExportData orchestrates the export process
 func ExportData(ctx context.Context, config *Config) error {
 	// Open database connection
-	connector, err := db.NewConnector(config.DSN)
+	connector, err := db.NewConnector(config.DSN, db.Options{})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -35,16 +36,25 @@ func ExportData(ctx context.Context, config *Config) error {
 
 	fmt.Fprintf(os.Stderr, "[INFO] Exporting %d columns: %v\n", len(columns), columns)
 
+	compress := CompressionOptions{
+		Compression: config.OutputCompression,
+		Level:       config.CompressionLevel,
+		Workers:     config.Workers,
+	}
+
 	// Select appropriate exporter
 	var exporter Exporter
 	switch config.OutputFormat {
 	case "csv":
-		exporter = NewCSVExporter(config.OutputFile, ',', columns)
+		exporter = NewCSVExporter(config.OutputFile, csvDialect(config, ','), config.OutputEncoding, columns, compress)
 	case "tsv":
-		exporter = NewCSVExporter(config.OutputFile, '\t', columns)
+		exporter = NewCSVExporter(config.OutputFile, csvDialect(config, '\t'), config.OutputEncoding, columns, compress)
 	case "jsonl":
-		exporter = NewJSONLExporter(config.OutputFile, columns)
+		exporter = NewJSONLExporter(config.OutputFile, columns, compress)
 	case "parquet":
+		if compress.Compression != "" && compress.Compression != CompressionNone {
+			return fmt.Errorf("output_compression %s is not supported for parquet, which compresses its own pages", compress.Compression)
+		}
 		exporter = NewParquetExporter(config.OutputFile, columns)
 	default:
 		return fmt.Errorf("unsupported output format: %s", config.OutputFormat)
@@ -120,6 +130,10 @@ func ExportData(ctx context.Context, config *Config) error {
 	if err := exporter.Flush(); err != nil {
 		return fmt.Errorf("failed to flush output: %w", err)
 	}
+	// A compressed file is only complete once its stream is closed
+	if err := exporter.Close(); err != nil {
+		return fmt.Errorf("failed to close output: %w", err)
+	}
 
 	finalCount := atomic.LoadInt64(&rowCount)
 	elapsed := time.Since(startTime).Seconds()
@@ -146,6 +160,32 @@ type Config struct {
 	BatchSize     int
 	Workers       int
 	ProgressEvery int
+
+	// Output compression, one of the Compression* constants other than auto
+	OutputCompression string
+	CompressionLevel  int    // 0 = codec default
+	OutputEncoding    string // csv and tsv, a name known to textfile.LookupEncoding ("" = UTF-8)
+
+	// CSV dialect; single characters are given as strings ("" = default)
+	CSVDelimiter string
+	CSVQuote     string // A character or textfile.QuoteNone
+	CSVEscape    string
+	CSVNoHeader  bool
+	CSVQuoteAll  bool
+	CSVCRLF      bool
+}
+
+// csvDialect builds the CSV dialect of the config. delimiter is the default
+// of the format.
+func csvDialect(config *Config, delimiter rune) CSVDialect {
+	return CSVDialect{
+		Delimiter: textfile.Rune(config.CSVDelimiter, delimiter),
+		Quote:     textfile.QuoteRune(config.CSVQuote),
+		Escape:    config.CSVEscape,
+		NoHeader:  config.CSVNoHeader,
+		QuoteAll:  config.CSVQuoteAll,
+		CRLF:      config.CSVCRLF,
+	}
 }
 
 // scanRow scans a SQL row into a slice
@@ -4,35 +4,36 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"os"
 )
 
 // JSONLExporter handles JSONL (newline-delimited JSON) file exports
 type JSONLExporter struct {
 	filePath string
 	columns  []string
-	file     *os.File
+	compress CompressionOptions
+	out      *output
 	writer   *bufio.Writer
 }
 
 // NewJSONLExporter creates a new JSONL exporter
-func NewJSONLExporter(filePath string, columns []string) *JSONLExporter {
+func NewJSONLExporter(filePath string, columns []string, compress CompressionOptions) *JSONLExporter {
 	return &JSONLExporter{
 		filePath: filePath,
 		columns:  columns,
+		compress: compress,
 	}
 }
 
 // Open opens the JSONL file
 func (j *JSONLExporter) Open() error {
-	file, err := os.Create(j.filePath)
+	out, err := createOutput(j.filePath, j.compress)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return err
 	}
-	j.file = file
+	j.out = out
 
 	// Create buffered writer for better performance
-	j.writer = bufio.NewWriterSize(file, 1024*1024) // 1MB buffer
+	j.writer = bufio.NewWriterSize(out, 1024*1024) // 1MB buffer
 
 	return nil
 }
@@ -73,13 +74,13 @@ func (j *JSONLExporter) Flush() error {
 	return j.writer.Flush()
 }
 
-// Close closes the JSONL file
+// Close closes the JSONL file, finishing the compressed stream if any
 func (j *JSONLExporter) Close() error {
 	if j.writer != nil {
 		j.writer.Flush()
 	}
-	if j.file != nil {
-		return j.file.Close()
+	if j.out != nil {
+		return j.out.Close()
 	}
 	return nil
 }
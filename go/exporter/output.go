@@ -0,0 +1,139 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Output compression
+const (
+	CompressionAuto = "auto" // From the output extension
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Compression levels accepted for each codec; 0 picks the codec default
+const (
+	MaxGzipLevel = 9
+	MaxZstdLevel = 22
+)
+
+// compressionExts maps output extensions to the compression they stand for
+var compressionExts = map[string]string{
+	".gz":   CompressionGzip,
+	".gzip": CompressionGzip,
+	".zst":  CompressionZstd,
+	".zstd": CompressionZstd,
+}
+
+// DetectCompression returns the compression implied by the extension of
+// an output path, so export.csv.gz is written with gzip
+func DetectCompression(filePath string) string {
+	if compression, ok := compressionExts[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return compression
+	}
+	return CompressionNone
+}
+
+// CompressionOptions selects how an exporter compresses its output
+type CompressionOptions struct {
+	Compression string // One of the Compression* constants other than auto ("" = none)
+	Level       int    // Codec level (0 = codec default)
+	Workers     int    // Blocks compressed in parallel (0 = number of CPUs)
+}
+
+// output is an output file written through an optional compressor
+type output struct {
+	file       *os.File
+	buffer     *bufio.Writer  // Compressed bytes on their way to the file
+	compressor io.WriteCloser // nil when uncompressed
+	writer     io.Writer
+}
+
+// createOutput creates a file and wraps it in the requested compressor
+func createOutput(filePath string, opts CompressionOptions) (*output, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	out := &output{file: file, writer: file}
+	if opts.Compression == "" || opts.Compression == CompressionNone {
+		return out, nil
+	}
+	out.buffer = bufio.NewWriterSize(file, 1024*1024)
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	switch opts.Compression {
+	case CompressionGzip:
+		level := opts.Level
+		if level == 0 {
+			level = pgzip.DefaultCompression
+		}
+		var gz *pgzip.Writer
+		gz, err = pgzip.NewWriterLevel(out.buffer, level)
+		if err == nil {
+			// pgzip compresses blocks of 1MB on separate goroutines
+			err = gz.SetConcurrency(1024*1024, workers)
+		}
+		out.compressor = gz
+	case CompressionZstd:
+		level := zstd.SpeedDefault
+		if opts.Level != 0 {
+			level = zstd.EncoderLevelFromZstd(opts.Level)
+		}
+		var enc *zstd.Encoder
+		enc, err = zstd.NewWriter(out.buffer, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(workers))
+		out.compressor = enc
+	default:
+		err = fmt.Errorf("unsupported output compression: %s", opts.Compression)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to start %s compression: %w", opts.Compression, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Compressing output with %s (%d workers)\n", opts.Compression, workers)
+	out.writer = out.compressor
+	return out, nil
+}
+
+// Write writes to the compressor, or straight to the file
+func (o *output) Write(p []byte) (int, error) {
+	return o.writer.Write(p)
+}
+
+// Close finishes the compressed stream and closes the file. Errors here
+// mean the output is truncated, so callers must check them.
+func (o *output) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	var err error
+	if o.compressor != nil {
+		err = o.compressor.Close()
+		if err == nil {
+			err = o.buffer.Flush()
+		}
+	}
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+	o.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to finish output: %w", err)
+	}
+	return nil
+}
@@ -68,12 +68,16 @@ func (p *ParquetExporter) Flush() error {
 // Close closes the Parquet file
 func (p *ParquetExporter) Close() error {
 	if p.writer != nil {
-		if err := p.writer.Close(); err != nil {
+		writer := p.writer
+		p.writer = nil
+		if err := writer.Close(); err != nil {
 			return err
 		}
 	}
 	if p.file != nil {
-		return p.file.Close()
+		file := p.file
+		p.file = nil
+		return file.Close()
 	}
 	return nil
 }
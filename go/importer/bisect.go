@@ -0,0 +1,40 @@
+package importer
+
+import (
+	"context"
+
+	"github.com/datamill/data-engine/go/db"
+)
+
+// insertFunc inserts a slice of rows as one unit
+type insertFunc func(ctx context.Context, rows [][]interface{}) error
+
+// rejectFunc records a row the database refused
+type rejectFunc func(line int64, row []interface{}, err error) error
+
+// bisectInsert inserts rows, and when the database refuses them because of
+// their data, retries each half until the offending rows are isolated. Good
+// rows are committed, bad rows are passed to reject. Errors unrelated to the
+// data (connection, syntax, cancellation) are returned unchanged. It returns
+// the number of rows committed.
+func bisectInsert(ctx context.Context, insert insertFunc, rows [][]interface{}, lines []int64, reject rejectFunc) (int64, error) {
+	err := insert(ctx, rows)
+	if err == nil {
+		return int64(len(rows)), nil
+	}
+	if ctx.Err() != nil || !db.IsRowError(err) {
+		return 0, err
+	}
+
+	if len(rows) == 1 {
+		return 0, reject(lines[0], rows[0], err)
+	}
+
+	mid := len(rows) / 2
+	left, err := bisectInsert(ctx, insert, rows[:mid], lines[:mid], reject)
+	if err != nil {
+		return left, err
+	}
+	right, err := bisectInsert(ctx, insert, rows[mid:], lines[mid:], reject)
+	return left + right, err
+}
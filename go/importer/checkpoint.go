@@ -0,0 +1,187 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/datamill/data-engine/go/worker"
+)
+
+// fingerprintChunk is how much of the head and tail of the input file is
+// hashed into the fingerprint
+const fingerprintChunk = 1024 * 1024
+
+// checkpointState is the content of a checkpoint file. Positions count rows
+// as returned by the importer, starting at 1.
+type checkpointState struct {
+	InputFile   string        `json:"input_file"`
+	Fingerprint string        `json:"fingerprint"`
+	Table       string        `json:"table"`
+	Committed   int64         `json:"committed"`        // Every row up to here is committed
+	Ranges      []worker.Span `json:"ranges,omitempty"` // Committed rows beyond Committed
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// checkpointer persists import progress so an interrupted import can resume
+type checkpointer struct {
+	mu    sync.Mutex
+	path  string
+	state checkpointState
+}
+
+// newCheckpointer prepares a checkpoint for the configured input file
+func newCheckpointer(config *Config) (*checkpointer, error) {
+	fingerprint, err := fileFingerprint(config.InputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint input file: %w", err)
+	}
+
+	return &checkpointer{
+		path: config.CheckpointFile,
+		state: checkpointState{
+			InputFile:   config.InputFile,
+			Fingerprint: fingerprint,
+			Table:       config.Table,
+		},
+	}, nil
+}
+
+// load reads an existing checkpoint and adopts its progress. It returns
+// false if there is no checkpoint to resume from.
+func (c *checkpointer) load() (bool, error) {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var saved checkpointState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return false, fmt.Errorf("invalid checkpoint file %s: %w", c.path, err)
+	}
+	if saved.Fingerprint != c.state.Fingerprint {
+		return false, fmt.Errorf("checkpoint %s was written for a different input file (fingerprint mismatch)", c.path)
+	}
+	if saved.Table != c.state.Table {
+		return false, fmt.Errorf("checkpoint %s was written for table %s, not %s", c.path, saved.Table, c.state.Table)
+	}
+
+	c.state.Committed = saved.Committed
+	c.state.Ranges = saved.Ranges
+	return true, nil
+}
+
+// update records progress reported by the worker pool and writes the
+// checkpoint file
+func (c *checkpointer) update(committed int64, pending []worker.Span) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if committed > c.state.Committed {
+		c.state.Committed = committed
+	}
+
+	// Keep ranges from a previous run that are still ahead of the run of
+	// committed rows, plus the batches completed out of order in this one
+	ranges := make([]worker.Span, 0, len(c.state.Ranges)+len(pending))
+	for _, span := range c.state.Ranges {
+		if span.Last > c.state.Committed {
+			ranges = append(ranges, span)
+		}
+	}
+	ranges = append(ranges, pending...)
+	c.state.Ranges = ranges
+	c.state.UpdatedAt = time.Now().UTC()
+
+	return c.writeLocked()
+}
+
+// writeLocked replaces the checkpoint file atomically
+func (c *checkpointer) writeLocked() error {
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the checkpoint file after a successful import
+func (c *checkpointer) remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// committedFilter reports whether a row position was already committed by
+// a previous run, for rows beyond the contiguous committed position
+type committedFilter struct {
+	ranges []worker.Span
+}
+
+// contains reports whether pos falls in a committed range. Positions must
+// be queried in increasing order.
+func (f *committedFilter) contains(pos int64) bool {
+	for len(f.ranges) > 0 && f.ranges[0].Last < pos {
+		f.ranges = f.ranges[1:]
+	}
+	return len(f.ranges) > 0 && f.ranges[0].First <= pos
+}
+
+// fileFingerprint hashes the size and the first and last MiB of a file.
+// This identifies the input without reading multi-GB files end to end.
+func fileFingerprint(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d:", info.Size())
+
+	if _, err := io.CopyN(hash, file, fingerprintChunk); err != nil && err != io.EOF {
+		return "", err
+	}
+	if info.Size() > 2*fingerprintChunk {
+		tail := io.NewSectionReader(file, info.Size()-fingerprintChunk, fingerprintChunk)
+		if _, err := io.Copy(hash, tail); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/datamill/data-engine/go/worker"
+)
+
+// writeFile creates a file in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		InputFile:      writeFile(t, dir, "data.csv", "id\n1\n2\n3\n4\n5\n"),
+		Table:          "items",
+		CheckpointFile: filepath.Join(dir, "import.ckpt"),
+	}
+
+	first, err := newCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.update(2, []worker.Span{{First: 4, Last: 4}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := newCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := resumed.load()
+	if err != nil || !ok {
+		t.Fatalf("load() = %v, %v, want true, nil", ok, err)
+	}
+	if resumed.state.Committed != 2 || !reflect.DeepEqual(resumed.state.Ranges, []worker.Span{{First: 4, Last: 4}}) {
+		t.Errorf("resumed at %d %v, want 2 [{4 4}]", resumed.state.Committed, resumed.state.Ranges)
+	}
+
+	// Ranges at or below the contiguous position are dropped
+	if err := resumed.update(4, nil); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.state.Committed != 4 || len(resumed.state.Ranges) != 0 {
+		t.Errorf("after update: %d %v, want 4 []", resumed.state.Committed, resumed.state.Ranges)
+	}
+}
+
+func TestCheckpointFingerprintMismatch(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		InputFile:      writeFile(t, dir, "data.csv", "id\n1\n2\n3\n"),
+		Table:          "items",
+		CheckpointFile: filepath.Join(dir, "import.ckpt"),
+	}
+
+	first, err := newCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.update(2, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same size, different content
+	writeFile(t, dir, "data.csv", "id\n7\n8\n9\n")
+	changed, err := newCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := changed.load()
+	if ok || err == nil || !strings.Contains(err.Error(), "fingerprint mismatch") {
+		t.Fatalf("load() = %v, %v, want a fingerprint mismatch", ok, err)
+	}
+	if changed.state.Committed != 0 {
+		t.Errorf("committed = %d after a mismatch, want 0", changed.state.Committed)
+	}
+}
+
+func TestCheckpointTableMismatch(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		InputFile:      writeFile(t, dir, "data.csv", "id\n1\n"),
+		Table:          "items",
+		CheckpointFile: filepath.Join(dir, "import.ckpt"),
+	}
+	first, err := newCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.update(1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	other := *config
+	other.Table = "archive"
+	resumed, err := newCheckpointer(&other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := resumed.load(); ok || err == nil {
+		t.Fatalf("load() = %v, %v, want a table mismatch", ok, err)
+	}
+}
+
+func TestCommittedFilter(t *testing.T) {
+	filter := committedFilter{ranges: []worker.Span{{First: 3, Last: 4}, {First: 8, Last: 8}}}
+	var got []int64
+	for pos := int64(1); pos <= 10; pos++ {
+		if filter.contains(pos) {
+			got = append(got, pos)
+		}
+	}
+	if want := []int64{3, 4, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("committed positions = %v, want %v", got, want)
+	}
+}
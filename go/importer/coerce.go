@@ -0,0 +1,315 @@
+package importer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/datamill/data-engine/go/db"
+)
+
+// numericPattern matches the decimal literals accepted by NUMERIC/DECIMAL
+var numericPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// dateLayouts are tried in order when parsing date columns
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+}
+
+// timestampLayouts are tried in order when parsing timestamp columns
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+}
+
+// FieldError describes a value that could not be converted to its column type
+type FieldError struct {
+	Column string
+	Value  interface{}
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("column %q: %v (value %q)", e.Column, e.Err, fmt.Sprintf("%v", e.Value))
+}
+
+// ConversionError collects the field errors of a rejected row
+type ConversionError struct {
+	Fields []*FieldError
+}
+
+func (e *ConversionError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// coercer converts raw file values into Go values matching the target
+// table's column types
+type coercer struct {
+	columns []db.ColumnInfo // Target column per imported column
+}
+
+// newCoercer matches the imported columns against the table definition
+func newCoercer(columns []string, table []db.ColumnInfo) (*coercer, error) {
+	byName := make(map[string]db.ColumnInfo, len(table))
+	for _, col := range table {
+		byName[col.Name] = col
+	}
+
+	c := &coercer{columns: make([]db.ColumnInfo, len(columns))}
+	for i, name := range columns {
+		col, ok := byName[name]
+		if !ok {
+			// Unquoted identifiers are folded by the database
+			col, ok = byName[strings.ToLower(name)]
+		}
+		if !ok {
+			return nil, fmt.Errorf("column %q does not exist in the target table", name)
+		}
+		c.columns[i] = col
+	}
+
+	return c, nil
+}
+
+// apply converts a row, returning a *ConversionError listing every field
+// that could not be converted. The input row is left untouched.
+func (c *coercer) apply(row []interface{}) ([]interface{}, error) {
+	out := make([]interface{}, len(row))
+	var convErr *ConversionError
+	for i := range row {
+		if i >= len(c.columns) {
+			break
+		}
+		col := c.columns[i]
+		val, err := coerceValue(col, row[i])
+		if err != nil {
+			if convErr == nil {
+				convErr = &ConversionError{}
+			}
+			convErr.Fields = append(convErr.Fields, &FieldError{Column: col.Name, Value: row[i], Err: err})
+			continue
+		}
+		out[i] = val
+	}
+
+	if convErr != nil {
+		return nil, convErr
+	}
+	return out, nil
+}
+
+// coerceValue converts a single value for the given column
+func coerceValue(col db.ColumnInfo, val interface{}) (interface{}, error) {
+	// Empty strings mean NULL for everything but text columns
+	if s, ok := val.(string); ok && s == "" && col.Kind != db.KindText {
+		val = nil
+	}
+	if val == nil {
+		if !col.Nullable {
+			return nil, fmt.Errorf("NULL in NOT NULL column")
+		}
+		return nil, nil
+	}
+
+	switch col.Kind {
+	case db.KindInteger:
+		return toInteger(val)
+	case db.KindFloat:
+		return toFloat(val)
+	case db.KindNumeric:
+		return toNumeric(val)
+	case db.KindBoolean:
+		return toBoolean(val)
+	case db.KindDate:
+		return toTime(val, dateLayouts, "date")
+	case db.KindTimestamp:
+		return toTime(val, timestampLayouts, "timestamp")
+	case db.KindBinary:
+		return toBinary(val)
+	case db.KindJSON:
+		return toJSON(val)
+	default:
+		return toText(val)
+	}
+}
+
+func toInteger(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		// float64(math.MaxInt64) rounds up to 2^63, which int64 cannot hold
+		if v != math.Trunc(v) || v >= 9.223372036854775807e18 || v < -9.223372036854775808e18 {
+			return nil, fmt.Errorf("not an integer")
+		}
+		return int64(v), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer")
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to integer", val)
+	}
+}
+
+func toFloat(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number")
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to number", val)
+	}
+}
+
+// toNumeric validates decimals but keeps them as strings so no precision
+// is lost on the way to NUMERIC/DECIMAL columns
+func toNumeric(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case string:
+		s := strings.TrimSpace(v)
+		if !numericPattern.MatchString(s) {
+			return nil, fmt.Errorf("invalid decimal")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to decimal", val)
+	}
+}
+
+func toBoolean(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "t", "yes", "y", "1", "on":
+			return true, nil
+		case "false", "f", "no", "n", "0", "off":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid boolean")
+}
+
+func toTime(val interface{}, layouts []string, kind string) (interface{}, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid %s", kind)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to %s", val, kind)
+	}
+}
+
+// toBinary accepts raw strings or PostgreSQL-style \x hex literals
+func toBinary(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if strings.HasPrefix(v, `\x`) {
+			b, err := hex.DecodeString(v[2:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex bytes")
+			}
+			return b, nil
+		}
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to bytes", val)
+	}
+}
+
+func toJSON(val interface{}) (interface{}, error) {
+	if s, ok := val.(string); ok {
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid JSON")
+		}
+		return s, nil
+	}
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+func toText(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []byte:
+		// Bytes that are not text become a \x hex literal, as toBinary reads
+		if utf8.Valid(v) {
+			return string(v), nil
+		}
+		return `\x` + hex.EncodeToString(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode JSON: %w", err)
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
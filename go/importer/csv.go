@@ -1,77 +1,273 @@
 package importer
 
 import (
+	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"unicode/utf8"
 )
 
 // CSVImporter handles CSV and TSV file imports
 type CSVImporter struct {
-	filePath  string
-	delimiter rune
-	file      *os.File
-	reader    *csv.Reader
-	columns   []string
+	filePath    string
+	dialect     CSVDialect
+	compression string
+	encoding    string
+	input       *Input
+	reader      recordReader
+	columns     []string
+	pending     []string // First record of a file without a header, not yet returned
+	pendingLine int64
+	line        int64
+	lineBase    int64 // Lines before the start of the input read by reader
+	dataStart   int64 // Offset of the first record after the header
 }
 
-// NewCSVImporter creates a new CSV importer
-func NewCSVImporter(filePath string, delimiter rune) *CSVImporter {
+// NewCSVImporter creates a new CSV importer. compression is one of the
+// Compression* constants other than auto; encoding is EncodingAuto or a
+// name known to textfile.LookupEncoding.
+func NewCSVImporter(filePath string, dialect CSVDialect, compression, encoding string) *CSVImporter {
 	return &CSVImporter{
-		filePath:  filePath,
-		delimiter: delimiter,
+		filePath:    filePath,
+		dialect:     dialect,
+		compression: compression,
+		encoding:    encoding,
 	}
 }
 
 // Open opens the CSV file and reads the header
 func (c *CSVImporter) Open() ([]string, error) {
-	file, err := os.Open(c.filePath)
+	input, err := OpenInput(c.filePath, c.compression, c.encoding)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, err
 	}
-	c.file = file
+	c.input = input
 
-	// Create CSV reader
-	c.reader = csv.NewReader(file)
-	c.reader.Comma = c.delimiter
-	c.reader.LazyQuotes = true
-	c.reader.TrimLeadingSpace = true
+	header, err := c.readHeader()
+	if err != nil {
+		c.input.Close()
+		return nil, err
+	}
+
+	c.columns = header
+	return header, nil
+}
+
+// readHeader starts a CSV reader on the current input, skips the leading
+// lines and reads the header. Without a header the first record is kept
+// for NextRow and the columns are named after their position.
+func (c *CSVImporter) readHeader() ([]string, error) {
+	buffered := bufio.NewReaderSize(c.input, 64*1024)
+	skipped, err := skipLines(buffered, c.dialect.SkipLines)
+	if err != nil {
+		return nil, err
+	}
+	c.lineBase = int64(c.dialect.SkipLines)
+	c.reader = newRecordReader(buffered, c.dialect)
+
+	if c.dialect.NoHeader {
+		record, err := c.reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read first row: %w", err)
+		}
+		line, _ := c.reader.FieldPos(0)
+		c.pending = record
+		c.pendingLine = c.lineBase + int64(line)
+		c.dataStart = c.input.Start() + skipped
+		return generatedColumns(len(record)), nil
+	}
 
 	// Read header row
 	header, err := c.reader.Read()
 	if err != nil {
-		c.file.Close()
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
-
-	c.columns = header
+	c.dataStart = c.input.Start() + skipped + c.reader.InputOffset()
 	return header, nil
 }
 
+// nextMember moves on to the next file of a zip archive, whose header must
+// match the first one. Line numbers start again in each file.
+func (c *CSVImporter) nextMember() (bool, error) {
+	if c.input == nil {
+		return false, nil
+	}
+	ok, err := c.input.NextMember()
+	if !ok || err != nil {
+		return false, err
+	}
+
+	header, err := c.readHeader()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", c.input.Member(), err)
+	}
+	if c.dialect.NoHeader {
+		return true, nil
+	}
+	if strings.Join(header, "\x00") != strings.Join(c.columns, "\x00") {
+		return false, fmt.Errorf("%s: header %v does not match the first file %v", c.input.Member(), header, c.columns)
+	}
+	return true, nil
+}
+
 // NextRow reads the next row from the CSV file
 func (c *CSVImporter) NextRow() ([]interface{}, error) {
+	if c.pending != nil {
+		record := c.pending
+		c.pending = nil
+		c.line = c.pendingLine
+		return c.fit(record)
+	}
+
 	record, err := c.reader.Read()
-	if err == io.EOF {
-		return nil, fmt.Errorf("EOF")
+	for err == io.EOF {
+		more, memberErr := c.nextMember()
+		if memberErr != nil {
+			return nil, memberErr
+		}
+		if !more {
+			return nil, fmt.Errorf("EOF")
+		}
+		if c.pending != nil {
+			return c.NextRow()
+		}
+		record, err = c.reader.Read()
 	}
 	if err != nil {
+		// Malformed records are reported per row; the reader can continue
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			c.line = c.lineBase + int64(parseErr.StartLine)
+			return nil, &RowError{Line: c.line, Values: stringsToRow(record), Err: parseErr.Err}
+		}
 		return nil, fmt.Errorf("failed to read row: %w", err)
 	}
 
-	// Convert string slice to interface slice
-	row := make([]interface{}, len(record))
-	for i, val := range record {
-		row[i] = val
+	line, _ := c.reader.FieldPos(0)
+	c.line = c.lineBase + int64(line)
+
+	return c.fit(record)
+}
+
+// fit matches the field count of a record to the columns as the dialect's
+// ragged row handling asks
+func (c *CSVImporter) fit(record []string) ([]interface{}, error) {
+	n := len(c.columns)
+	switch {
+	case len(record) == n:
+		return stringsToRow(record), nil
+	case len(record) > n && c.dialect.Ragged == RaggedTruncate:
+		return stringsToRow(record[:n]), nil
+	case len(record) < n && (c.dialect.Ragged == RaggedPad || c.dialect.Ragged == RaggedTruncate):
+		row := make([]interface{}, n)
+		copy(row, stringsToRow(record))
+		return row, nil
+	}
+	return nil, &RowError{
+		Line:   c.line,
+		Values: stringsToRow(record),
+		Err:    fmt.Errorf("%w: %d fields, expected %d", csv.ErrFieldCount, len(record), n),
+	}
+}
+
+// Skip reads past n records without converting them. Malformed records
+// count as rows, as they do for NextRow.
+func (c *CSVImporter) Skip(n int64) (int64, error) {
+	var skipped int64
+	for skipped < n {
+		if c.pending != nil {
+			c.pending = nil
+			skipped++
+			continue
+		}
+		_, err := c.reader.Read()
+		if err == io.EOF {
+			more, err := c.nextMember()
+			if err != nil {
+				return skipped, err
+			}
+			if !more {
+				break
+			}
+			continue
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return skipped, fmt.Errorf("failed to skip row: %w", err)
+		}
+		skipped++
+	}
+	return skipped, nil
+}
+
+// split divides the records after the header into byte ranges with a
+// reader each. Readers share the open file, which is read with ReadAt.
+// The record scanner knows double-quoted fields only, without comments.
+func (c *CSVImporter) split(n int) ([]rowReader, error) {
+	if c.dialect.Delimiter >= utf8.RuneSelf || !c.dialect.standard() || c.dialect.Comment != 0 {
+		fmt.Fprintf(os.Stderr, "[WARN] parse_workers ignored, the CSV dialect is read by a single reader\n")
+		return []rowReader{c}, nil
+	}
+	file := c.input.File()
+	if file == nil {
+		fmt.Fprintf(os.Stderr, "[WARN] parse_workers ignored, compressed or re-encoded input is read by a single reader\n")
+		return []rowReader{c}, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to split input: %w", err)
+	}
+	start := c.dataStart
+	lines, err := countNewlines(file, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split input: %w", err)
+	}
+
+	scanner := newRecordScanner(byte(c.dialect.Delimiter), true, !c.dialect.KeepSpaces)
+	ranges, err := scanner.splitRanges(file, start, info.Size(), lines, n)
+	if err != nil {
+		return nil, err
 	}
 
-	return row, nil
+	readers := make([]rowReader, len(ranges))
+	for i, r := range ranges {
+		readers[i] = &CSVImporter{
+			filePath: c.filePath,
+			dialect:  c.dialect,
+			reader:   newRecordReader(io.NewSectionReader(file, r.start, r.end-r.start), c.dialect),
+			columns:  c.columns,
+			lineBase: r.lines,
+		}
+	}
+	return readers, nil
+}
+
+// Line returns the line on which the last row returned by NextRow started
+func (c *CSVImporter) Line() int64 {
+	return c.line
 }
 
 // Close closes the CSV file
 func (c *CSVImporter) Close() error {
-	if c.file != nil {
-		return c.file.Close()
+	if c.input != nil {
+		return c.input.Close()
 	}
 	return nil
 }
+
+// stringsToRow converts a string slice to an interface slice
+func stringsToRow(record []string) []interface{} {
+	if record == nil {
+		return nil
+	}
+	row := make([]interface{}, len(record))
+	for i, val := range record {
+		row[i] = val
+	}
+	return row
+}
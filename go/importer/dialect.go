@@ -0,0 +1,268 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/datamill/data-engine/go/textfile"
+)
+
+// Rows whose field count differs from the columns
+const (
+	RaggedError    = "error"    // Rejected
+	RaggedPad      = "pad"      // Short rows padded with NULL, long rows rejected
+	RaggedTruncate = "truncate" // Short rows padded with NULL, extra fields dropped
+)
+
+// CSVDialect describes how a delimited file is laid out
+type CSVDialect struct {
+	Delimiter    rune   // Field separator
+	Quote        rune   // Quote character (0 = fields are never quoted)
+	Escape       string // One of the textfile.Escape* constants ("" = double)
+	Comment      rune   // Lines starting with it are skipped (0 = none)
+	NoHeader     bool   // The first record is data; columns are named column_1, column_2, ...
+	SkipLines    int    // Lines skipped before the header
+	Ragged       string // One of the Ragged* constants ("" = error)
+	KeepSpaces   bool   // Keep the leading spaces of fields
+	StrictQuotes bool   // Reject bare quotes in unquoted fields and stray quotes in quoted ones
+}
+
+// standard reports whether encoding/csv can read the dialect
+func (d CSVDialect) standard() bool {
+	return d.Quote == '"' && d.Escape != textfile.EscapeBackslash
+}
+
+// recordReader reads one CSV record at a time. csv.Reader is one.
+type recordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+	InputOffset() int64
+}
+
+// newRecordReader returns a reader for the dialect, using encoding/csv
+// whenever it can
+func newRecordReader(r io.Reader, d CSVDialect) recordReader {
+	if !d.standard() {
+		return &dialectReader{r: bufio.NewReaderSize(r, 64*1024), dialect: d}
+	}
+	reader := csv.NewReader(r)
+	reader.Comma = d.Delimiter
+	reader.Comment = d.Comment
+	reader.LazyQuotes = !d.StrictQuotes
+	reader.TrimLeadingSpace = !d.KeepSpaces
+	reader.FieldsPerRecord = -1 // Field counts are checked against the columns
+	return reader
+}
+
+// skipLines reads past n lines and returns the number of bytes skipped
+func skipLines(r *bufio.Reader, n int) (int64, error) {
+	var skipped int64
+	for i := 0; i < n; i++ {
+		line, err := r.ReadSlice('\n')
+		skipped += int64(len(line))
+		for err == bufio.ErrBufferFull {
+			line, err = r.ReadSlice('\n')
+			skipped += int64(len(line))
+		}
+		if err == io.EOF {
+			return skipped, nil
+		}
+		if err != nil {
+			return skipped, fmt.Errorf("failed to skip leading lines: %w", err)
+		}
+	}
+	return skipped, nil
+}
+
+// dialectReader reads the dialects encoding/csv does not: a quote character
+// other than the double quote, no quoting at all, or backslash escapes.
+// Otherwise it behaves like encoding/csv: empty lines are skipped, \r\n
+// reads as \n and malformed records come back as *csv.ParseError.
+type dialectReader struct {
+	r          *bufio.Reader
+	dialect    CSVDialect
+	line       int   // Newlines read so far
+	offset     int64 // Bytes read so far
+	last       rune  // Last rune read
+	recordLine int
+	field      strings.Builder
+}
+
+// readRune reads a rune, keeping count of lines and bytes
+func (r *dialectReader) readRune() (rune, error) {
+	ch, size, err := r.r.ReadRune()
+	r.offset += int64(size)
+	if ch == '\n' {
+		r.line++
+	}
+	if err == nil {
+		r.last = ch
+	}
+	return ch, err
+}
+
+// peekRune returns the next rune without reading it
+func (r *dialectReader) peekRune() (rune, error) {
+	ch, _, err := r.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	r.r.UnreadRune()
+	return ch, nil
+}
+
+// skipLine reads up to and including the next newline
+func (r *dialectReader) skipLine() {
+	for {
+		ch, err := r.readRune()
+		if err != nil || ch == '\n' {
+			return
+		}
+	}
+}
+
+// Read reads the next record
+func (r *dialectReader) Read() ([]string, error) {
+	// Skip empty lines and comments
+	for {
+		ch, err := r.peekRune()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ch == '\n':
+			r.readRune()
+			continue
+		case ch == '\r':
+			if next, _ := r.r.Peek(2); string(next) == "\r\n" {
+				r.readRune()
+				r.readRune()
+				continue
+			}
+		case r.dialect.Comment != 0 && ch == r.dialect.Comment:
+			r.skipLine()
+			continue
+		}
+		break
+	}
+
+	r.recordLine = r.line + 1
+	var record []string
+	for {
+		field, more, err := r.readField()
+		record = append(record, field)
+		if err != nil {
+			r.skipLine()
+			return record, err
+		}
+		if !more {
+			return record, nil
+		}
+	}
+}
+
+// readField reads one field and reports whether more follow in the record
+func (r *dialectReader) readField() (string, bool, error) {
+	d := r.dialect
+	backslash := d.Escape == textfile.EscapeBackslash
+	r.field.Reset()
+
+	if !d.KeepSpaces {
+		for {
+			ch, err := r.peekRune()
+			if err != nil || (ch != ' ' && ch != '\t') || ch == d.Delimiter {
+				break
+			}
+			r.readRune()
+		}
+	}
+	quoted := false
+	if ch, err := r.peekRune(); err == nil && d.Quote != 0 && ch == d.Quote {
+		r.readRune()
+		quoted = true
+	}
+
+	for {
+		ch, err := r.readRune()
+		if err == io.EOF {
+			if quoted && d.StrictQuotes {
+				// The input ends on the line of its last character
+				line := r.line + 1
+				if r.last == '\n' {
+					line = r.line
+				}
+				return r.field.String(), false, r.parseError(line, csv.ErrQuote)
+			}
+			return r.field.String(), false, nil
+		}
+		if err != nil {
+			return r.field.String(), false, err
+		}
+
+		switch {
+		case backslash && ch == '\\':
+			next, err := r.readRune()
+			if err != nil {
+				r.field.WriteRune(ch)
+				continue
+			}
+			r.field.WriteRune(next)
+		case quoted && ch == d.Quote:
+			next, err := r.peekRune()
+			switch {
+			case !backslash && err == nil && next == d.Quote:
+				r.readRune()
+				r.field.WriteRune(ch) // Doubled quote
+			case err != nil || next == d.Delimiter || next == '\n' || next == '\r':
+				quoted = false // Closing quote
+			case d.StrictQuotes:
+				return r.field.String(), false, r.parseError(r.line+1, csv.ErrQuote)
+			default:
+				r.field.WriteRune(ch) // Stray quote, kept as with LazyQuotes
+			}
+		case ch == '\r':
+			if next, err := r.peekRune(); err == nil && next == '\n' {
+				continue // \r\n reads as \n
+			}
+			r.field.WriteRune(ch)
+		case quoted:
+			r.field.WriteRune(ch)
+		case ch == d.Delimiter:
+			return r.field.String(), true, nil
+		case ch == '\n':
+			return r.field.String(), false, nil
+		case d.Quote != 0 && ch == d.Quote && d.StrictQuotes:
+			return r.field.String(), false, r.parseError(r.line+1, csv.ErrBareQuote)
+		default:
+			r.field.WriteRune(ch)
+		}
+	}
+}
+
+// parseError reports a malformed record found on line
+func (r *dialectReader) parseError(line int, err error) error {
+	return &csv.ParseError{StartLine: r.recordLine, Line: line, Err: err}
+}
+
+// FieldPos returns the line on which the last record started. Columns are
+// not tracked.
+func (r *dialectReader) FieldPos(field int) (int, int) {
+	return r.recordLine, 1
+}
+
+// InputOffset returns the number of bytes read so far
+func (r *dialectReader) InputOffset() int64 {
+	return r.offset
+}
+
+// generatedColumns names the columns of a file without a header
+func generatedColumns(n int) []string {
+	columns := make([]string, n)
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column_%d", i+1)
+	}
+	return columns
+}
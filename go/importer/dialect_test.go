@@ -0,0 +1,191 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/datamill/data-engine/go/exporter"
+	"github.com/datamill/data-engine/go/textfile"
+)
+
+// roundTripFields are written out and must read back unchanged
+var roundTripFields = [][]string{
+	{"plain", "", "with,comma"},
+	{`with "double" quotes`, "it's", "multi\nline"},
+	{" leading space", "back\\slash", "tab\tinside"},
+	{"unicode é ✓", "semi;colon", `\"`},
+}
+
+func TestDialectRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  exporter.CSVDialect
+		read   CSVDialect
+		fields [][]string // Defaults to roundTripFields
+	}{
+		{
+			name:  "standard",
+			write: exporter.CSVDialect{Delimiter: ',', Quote: '"'},
+			read:  CSVDialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name:  "quote all with CRLF",
+			write: exporter.CSVDialect{Delimiter: ',', Quote: '"', QuoteAll: true, CRLF: true},
+			read:  CSVDialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name:  "single quote",
+			write: exporter.CSVDialect{Delimiter: ',', Quote: '\''},
+			read:  CSVDialect{Delimiter: ',', Quote: '\''},
+		},
+		{
+			name:  "backslash escape",
+			write: exporter.CSVDialect{Delimiter: ',', Quote: '"', Escape: textfile.EscapeBackslash},
+			read:  CSVDialect{Delimiter: ',', Quote: '"', Escape: textfile.EscapeBackslash},
+		},
+		{
+			name:  "semicolon and single quote with backslash escape",
+			write: exporter.CSVDialect{Delimiter: ';', Quote: '\'', Escape: textfile.EscapeBackslash},
+			read:  CSVDialect{Delimiter: ';', Quote: '\'', Escape: textfile.EscapeBackslash},
+		},
+		{
+			name:  "unquoted with backslash escape",
+			write: exporter.CSVDialect{Delimiter: '\t', Escape: textfile.EscapeBackslash},
+			read:  CSVDialect{Delimiter: '\t', Escape: textfile.EscapeBackslash, KeepSpaces: true},
+		},
+		{
+			name:  "unquoted",
+			write: exporter.CSVDialect{Delimiter: '|'},
+			read:  CSVDialect{Delimiter: '|', KeepSpaces: true},
+			fields: [][]string{
+				{"plain", "", `"quoted"`},
+				{" leading space", "back\\slash", "it's"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.fields
+			if fields == nil {
+				fields = roundTripFields
+			}
+			header := []string{"a", "b", "c"}
+			path := filepath.Join(t.TempDir(), "data.csv")
+
+			exp := exporter.NewCSVExporter(path, tt.write, "", header, exporter.CompressionOptions{})
+			if err := exp.Open(); err != nil {
+				t.Fatal(err)
+			}
+			for _, record := range fields {
+				if err := exp.WriteRow(stringsToRow(record)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := exp.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			imp := NewCSVImporter(path, tt.read, CompressionNone, EncodingAuto)
+			columns, err := imp.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer imp.Close()
+			if !reflect.DeepEqual(columns, header) {
+				t.Errorf("header = %q, want %q", columns, header)
+			}
+			rows, _ := readRows(t, imp)
+			if want := stringRows(fields); !reflect.DeepEqual(rows, want) {
+				t.Errorf("rows = %q, want %q", rows, want)
+			}
+		})
+	}
+}
+
+// stringRows converts records the way the CSV importer returns them
+func stringRows(records [][]string) [][]interface{} {
+	rows := make([][]interface{}, len(records))
+	for i, record := range records {
+		rows[i] = stringsToRow(record)
+	}
+	return rows
+}
+
+// readResult is what one Read call returned
+type readResult struct {
+	record    []string
+	line      int
+	startLine int // Of a parse error
+	errLine   int
+	err       error
+}
+
+// readAll reads every record of r, noting the line each started on
+func readAll(r recordReader) []readResult {
+	var results []readResult
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return results
+		}
+		result := readResult{}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			result.startLine, result.errLine, result.err = parseErr.StartLine, parseErr.Line, parseErr.Err
+		case err != nil:
+			result.err = err
+		default:
+			result.record = record
+			result.line, _ = r.FieldPos(0)
+		}
+		results = append(results, result)
+		if err != nil && parseErr == nil {
+			return results
+		}
+	}
+}
+
+func TestDialectReaderParity(t *testing.T) {
+	inputs := []struct {
+		name  string
+		input string
+	}{
+		{"simple", "a,b,c\n1,2,3\n"},
+		{"no final newline", "a,b\n1,2"},
+		{"empty fields", ",,\n,x,\n"},
+		{"empty lines", "\na,b\n\n\r\n1,2\n\n"},
+		{"CRLF", "a,b\r\n1,2\r\n"},
+		{"quoted", "\"a,b\",\"c\"\"d\"\n\"\",x\n"},
+		{"quoted newline", "\"multi\nline\",x\n\"crlf\r\nline\",y\nlast,z\n"},
+		{"leading spaces", "  a, \"b\",\t c\n"},
+		{"lone carriage return", "a\rb,c\n"},
+		{"comment", "#skip\na,b\n#skip,too\n1,2\n"},
+		{"bare quote", "a,b\"c,d\ne,f\n"},
+		{"stray quote in quoted field", "\"a\"b,c\nd,e\n"},
+		{"quote after spaces", "\"a\" ,b\nc,d\n"},
+		{"unterminated quote", "a,b\n\"c,d\ne,f\n"},
+		{"unterminated quote at end", "a,b\n\"c,d"},
+		{"error after good lines", "a,b\n1,2\n3,\"4\"x\n5,6\n"},
+	}
+
+	for _, strict := range []bool{false, true} {
+		for _, keepSpaces := range []bool{false, true} {
+			dialect := CSVDialect{Delimiter: ',', Quote: '"', Comment: '#', StrictQuotes: strict, KeepSpaces: keepSpaces}
+			for _, in := range inputs {
+				want := readAll(newRecordReader(strings.NewReader(in.input), dialect))
+				got := readAll(&dialectReader{r: bufio.NewReader(strings.NewReader(in.input)), dialect: dialect})
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s (strict %v, keep spaces %v):\n got %+v\nwant %+v", in.name, strict, keepSpaces, got, want)
+				}
+			}
+		}
+	}
+}
@@ -2,35 +2,120 @@ package importer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/datamill/data-engine/go/db"
+	"github.com/datamill/data-engine/go/textfile"
 	"github.com/datamill/data-engine/go/worker"
 )
 
 // ImportData orchestrates the import process
 func ImportData(ctx context.Context, config *Config) error {
+	if config.InputFormat == "xlsx" && config.XLSXSheets == SheetsPerTable {
+		return importSheets(ctx, config)
+	}
+	return importTable(ctx, config)
+}
+
+// importSheets imports every sheet of a workbook into its own table, named
+// by expanding XLSXTableTemplate. Sheets are imported one after the other
+// and the first failure stops the rest.
+func importSheets(ctx context.Context, config *Config) error {
+	sheets, err := XLSXSheetList(config.InputFile, xlsxOptions(config))
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+
+	// Sheet names that reduce to the same table would load into one table,
+	// a later sheet replacing an earlier one with truncate or swap
+	tables := make([]string, len(sheets))
+	seen := make(map[string]string, len(sheets))
+	for i, sheet := range sheets {
+		tables[i] = sheetTableName(config.XLSXTableTemplate, config.Table, sheet, i+1)
+		key := strings.ToLower(tables[i])
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("sheets %q and %q both map to table %s; add {index} to xlsx_table_template or rename a sheet", other, sheet, tables[i])
+		}
+		seen[key] = sheet
+	}
+
+	for i, sheet := range sheets {
+		sheetConfig := *config
+		sheetConfig.Table = tables[i]
+		sheetConfig.XLSXSheet = sheet
+		sheetConfig.XLSXSheetIndex = 0
+		sheetConfig.XLSXSheets = SheetsSingle
+		sheetConfig.appendRejects = i > 0
+
+		fmt.Fprintf(os.Stderr, "[INFO] Importing sheet %d/%d %q into %s\n", i+1, len(sheets), sheet, sheetConfig.Table)
+		if err := importTable(ctx, &sheetConfig); err != nil {
+			return fmt.Errorf("sheet %q: %w", sheet, err)
+		}
+	}
+
+	return nil
+}
+
+// importTable imports the input file into a single table
+func importTable(ctx context.Context, config *Config) error {
 	// Open database connection
-	connector, err := db.NewConnector(config.DSN)
+	connector, err := db.NewConnector(config.DSN, db.Options{
+		InsertMethod:  config.InsertMethod,
+		OnConflict:    config.OnConflict,
+		ConflictKeys:  config.ConflictKeys,
+		UpdateColumns: config.UpdateColumns,
+		LoadStrategy:  config.LoadStrategy,
+		DeleteWhere:   config.DeleteWhere,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer connector.Close()
 
+	// Workbooks and Parquet files are read with random access
+	if (config.InputFormat == "xlsx" || config.InputFormat == "parquet") && config.InputCompression != CompressionNone {
+		return fmt.Errorf("%s input cannot be read from a %s file, decompress it first", config.InputFormat, config.InputCompression)
+	}
+	if (config.InputFormat == "xlsx" || config.InputFormat == "parquet") && config.InputEncoding != "" && config.InputEncoding != EncodingAuto {
+		return fmt.Errorf("input_encoding does not apply to %s input", config.InputFormat)
+	}
+
+	// A fixed column list replaces the columns discovered in the file
+	if len(config.Columns) > 0 && config.InputFormat != "jsonl" && config.InputFormat != "json" && config.InputFormat != "parquet" {
+		return fmt.Errorf("columns is only supported for jsonl, json and parquet input, got: %s", config.InputFormat)
+	}
+
+	jsonOptions := JSONLOptions{
+		Discovery: config.JSONLDiscovery,
+		Sample:    config.JSONLDiscoverySample,
+		Columns:   config.Columns,
+		Nested:    config.JSONLNested,
+		Separator: config.JSONLSeparator,
+		MaxDepth:  config.JSONLMaxDepth,
+		Arrays:    config.JSONLArrays,
+	}
+
 	// Detect and route to appropriate importer
 	var importer Importer
 	switch config.InputFormat {
 	case "csv":
-		importer = NewCSVImporter(config.InputFile, ',')
+		importer = NewCSVImporter(config.InputFile, csvDialect(config, ','), config.InputCompression, config.InputEncoding)
 	case "tsv":
-		importer = NewCSVImporter(config.InputFile, '\t')
+		importer = NewCSVImporter(config.InputFile, csvDialect(config, '\t'), config.InputCompression, config.InputEncoding)
 	case "jsonl":
-		importer = NewJSONLImporter(config.InputFile)
+		importer = NewJSONLImporter(config.InputFile, config.InputCompression, config.InputEncoding, jsonOptions)
+	case "json":
+		importer = NewJSONImporter(config.InputFile, config.JSONPointer, config.InputCompression, config.InputEncoding, jsonOptions)
 	case "xlsx":
-		importer = NewXLSXImporter(config.InputFile)
+		importer = NewXLSXImporter(config.InputFile, xlsxOptions(config))
+	case "parquet":
+		importer = NewParquetImporter(config.InputFile, config.Columns)
 	default:
 		return fmt.Errorf("unsupported input format: %s", config.InputFormat)
 	}
@@ -41,14 +126,174 @@ func ImportData(ctx context.Context, config *Config) error {
 		return fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer importer.Close()
+	source := importer
 
 	fmt.Fprintf(os.Stderr, "[INFO] Detected %d columns: %v\n", len(columns), columns)
 
+	// Pick up where an interrupted run left off
+	var checkpoint *checkpointer
+	var resumed committedFilter
+	var skipped int64
+	var resuming bool
+	if config.CheckpointFile != "" {
+		checkpoint, err = newCheckpointer(config)
+		if err != nil {
+			return err
+		}
+	}
+	if config.Resume {
+		found, err := checkpoint.load()
+		if err != nil {
+			return err
+		}
+		if found {
+			resuming = true
+			skipped, err = importer.Skip(checkpoint.state.Committed)
+			if err != nil {
+				return fmt.Errorf("failed to skip committed rows: %w", err)
+			}
+			if skipped < checkpoint.state.Committed {
+				return fmt.Errorf("checkpoint %s records %d committed rows but the input only has %d", config.CheckpointFile, checkpoint.state.Committed, skipped)
+			}
+			resumed.ranges = checkpoint.state.Ranges
+			fmt.Fprintf(os.Stderr, "[INFO] Resuming from checkpoint %s: skipped %d committed rows\n", config.CheckpointFile, skipped)
+		} else {
+			fmt.Fprintf(os.Stderr, "[INFO] No checkpoint at %s, starting from the beginning\n", config.CheckpointFile)
+		}
+	}
+
+	// Map file columns onto table columns before anything is submitted
+	var mapper *columnMapper
+	if !config.ColumnMapping.isEmpty() {
+		mapper, err = newColumnMapper(config.ColumnMapping, columns)
+		if err != nil {
+			return fmt.Errorf("invalid column mapping: %w", err)
+		}
+		columns = mapper.columns
+		fmt.Fprintf(os.Stderr, "[INFO] Mapped to %d table columns: %v\n", len(columns), columns)
+	}
+
+	// Create the target table from a sample of the input
+	if config.CreateTable {
+		sample, replay, err := sampleRows(importer, config.CreateTableSample)
+		if err != nil {
+			return err
+		}
+		importer = replay
+
+		if mapper != nil {
+			for i, row := range sample {
+				sample[i] = mapper.apply(row)
+			}
+		}
+
+		inferred := inferSchema(columns, sample)
+		ddl := connector.CreateTableSQL(config.Table, inferred)
+
+		if config.CreateTableDryRun {
+			fmt.Fprintf(os.Stdout, "%s;\n", ddl)
+			fmt.Fprintf(os.Stderr, "[INFO] Dry run: printed DDL for %s inferred from %d rows, nothing loaded\n", config.Table, len(sample))
+			return nil
+		}
+
+		_, err = connector.DescribeTable(ctx, config.Table)
+		switch {
+		case err == nil:
+			fmt.Fprintf(os.Stderr, "[INFO] Table %s already exists, skipping create\n", config.Table)
+		case errors.Is(err, db.ErrTableNotFound):
+			fmt.Fprintf(os.Stderr, "[INFO] Creating table %s inferred from %d rows:\n%s\n", config.Table, len(sample), ddl)
+			if err := connector.CreateTable(ctx, config.Table, inferred); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("failed to inspect target table: %w", err)
+		}
+	}
+
+	// Conflict handling can only reference columns that are being loaded
+	if err := checkConflictColumns(config, columns); err != nil {
+		return err
+	}
+
+	// Convert values to the target column types
+	var coerce *coercer
+	if !config.DisableTypeCoercion {
+		tableColumns, err := connector.DescribeTable(ctx, config.Table)
+		if err != nil {
+			return fmt.Errorf("failed to inspect target table: %w", err)
+		}
+		coerce, err = newCoercer(columns, tableColumns)
+		if err != nil {
+			return fmt.Errorf("table %s does not match the input: %w", config.Table, err)
+		}
+	}
+
+	// In transactional mode rows are loaded into a staging table and only
+	// published to the target once every batch has succeeded. The swap
+	// strategy loads a shadow table that replaces the target at the end.
+	loadTable := config.Table
+	switch {
+	case config.LoadStrategy == db.LoadSwap:
+		lost, err := connector.CheckSwap(ctx, config.Table)
+		if err != nil {
+			return err
+		}
+		if len(lost) > 0 {
+			fmt.Fprintf(os.Stderr, "[WARN] load_strategy swap replaces %s with a copy that lacks its %s\n", config.Table, strings.Join(lost, ", "))
+		}
+		loadTable = workTableName(config.Table, "shadow")
+	case config.Transactional:
+		loadTable = workTableName(config.Table, "staging")
+	case config.LoadStrategy != db.LoadAppend && !resuming:
+		// A resumed run already cleared the table before loading
+		removed, err := connector.ClearTable(ctx, config.Table)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[INFO] Cleared table %s (%s, %d rows deleted)\n", config.Table, config.LoadStrategy, removed)
+	}
+	if loadTable != config.Table {
+		if err := connector.CreateStagingTable(ctx, config.Table, loadTable); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[INFO] Loading into %s\n", loadTable)
+
+		// The work table is dropped whether or not it was published; the
+		// context may already be cancelled at this point
+		defer func() {
+			if err := connector.DropTable(context.Background(), loadTable); err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN] %v\n", err)
+			}
+		}()
+	}
+
+	// Split the input into byte ranges parsed in parallel
+	readers := []rowReader{importer}
+	if config.ParseWorkers > 1 {
+		if s, ok := source.(splitter); ok {
+			readers, err = s.split(config.ParseWorkers)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "[INFO] Parsing %d byte ranges in parallel\n", len(readers))
+		} else {
+			fmt.Fprintf(os.Stderr, "[WARN] parse_workers ignored, %s input is read by a single reader\n", config.InputFormat)
+		}
+	}
+
 	// Create worker pool
 	pool := worker.NewPool(ctx, config.Workers, config.BatchSize)
 
+	// Rejected rows and the error budget
+	rejects, err := newRejectLog(config.RejectFile, config.MaxErrors, config.MaxErrorRatio, skipped > 0 || config.appendRejects)
+	if err != nil {
+		return err
+	}
+	defer rejects.Close()
+
 	// Progress tracking
 	var rowCount int64
+	rowsRead := skipped
 	startTime := time.Now()
 
 	// Start progress reporter
@@ -69,43 +314,146 @@ func ImportData(ctx context.Context, config *Config) error {
 		}
 	}()
 
-	// Batch processor
-	processBatch := func(ctx context.Context, rows [][]interface{}) error {
-		if err := connector.BatchInsert(ctx, config.Table, columns, rows); err != nil {
-			return err
-		}
-		atomic.AddInt64(&rowCount, int64(len(rows)))
-		return nil
+	// Batch processor: failed batches are bisected to isolate bad rows
+	insert := func(ctx context.Context, rows [][]interface{}) error {
+		return connector.BatchInsert(ctx, loadTable, columns, rows)
+	}
+	rejectRow := func(line int64, row []interface{}, err error) error {
+		return rejects.reject(line, row, "", err, atomic.LoadInt64(&rowsRead))
+	}
+	processBatch := func(ctx context.Context, batch worker.Batch) error {
+		committed, err := bisectInsert(ctx, insert, batch.Rows, batch.Lines, rejectRow)
+		atomic.AddInt64(&rowCount, committed)
+		return err
+	}
+
+	// Record progress as batches commit
+	if checkpoint != nil {
+		pool.OnCommit(func(committed int64, pending []worker.Span) {
+			if err := checkpoint.update(committed, pending); err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN] %v\n", err)
+			}
+		})
 	}
 
 	// Start workers
 	pool.Start(processBatch)
 
 	// Read and submit rows
-	for {
-		row, err := importer.NextRow()
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
+	readRows := func(reader rowReader) error {
+		for {
+			row, err := reader.NextRow()
+			if err != nil {
+				if err.Error() == "EOF" {
+					return nil
+				}
+
+				// Unparseable rows count against the error budget
+				var rowErr *RowError
+				if errors.As(err, &rowErr) {
+					read := atomic.AddInt64(&rowsRead, 1)
+					if resumed.contains(read) {
+						continue
+					}
+					if err := rejects.reject(rowErr.Line, rowErr.Values, rowErr.Raw, rowErr.Err, read); err != nil {
+						return err
+					}
+					continue
+				}
+
+				return fmt.Errorf("failed to read row: %w", err)
 			}
-			pool.Cancel()
-			return fmt.Errorf("failed to read row: %w", err)
-		}
 
-		if err := pool.Submit(row); err != nil {
-			return fmt.Errorf("failed to submit row: %w", err)
+			read := atomic.AddInt64(&rowsRead, 1)
+			line := reader.Line()
+
+			// Rows committed out of order by the previous run
+			if resumed.contains(read) {
+				continue
+			}
+
+			if mapper != nil {
+				row = mapper.apply(row)
+			}
+
+			if coerce != nil {
+				converted, err := coerce.apply(row)
+				if err != nil {
+					if err := rejects.reject(line, row, "", err, read); err != nil {
+						return err
+					}
+					continue
+				}
+				row = converted
+			}
+
+			if err := pool.Submit(read, line, row); err != nil {
+				return fmt.Errorf("failed to submit row: %w", err)
+			}
 		}
 	}
 
+	// Each reader feeds the pool from its own goroutine; the first error
+	// stops the others
+	readErrs := make(chan error, len(readers))
+	var readWg sync.WaitGroup
+	for _, reader := range readers {
+		readWg.Add(1)
+		go func(reader rowReader) {
+			defer readWg.Done()
+			if err := readRows(reader); err != nil {
+				readErrs <- err
+				pool.Cancel()
+			}
+		}(reader)
+	}
+	readWg.Wait()
+	close(readErrs)
+	if err := <-readErrs; err != nil {
+		return err
+	}
+
 	// Wait for all workers to finish
 	if err := pool.Close(); err != nil {
 		return fmt.Errorf("worker pool error: %w", err)
 	}
 
+	rejected := rejects.count()
+	if err := rejects.checkRatio(atomic.LoadInt64(&rowsRead)); err != nil {
+		return err
+	}
+
+	switch {
+	case config.LoadStrategy == db.LoadSwap:
+		retired := workTableName(config.Table, "retired")
+		if err := connector.SwapTables(ctx, config.Table, loadTable, retired); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[INFO] Swapped %s into %s\n", loadTable, config.Table)
+		if err := connector.DropTable(context.Background(), retired); err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] %v\n", err)
+		}
+	case config.Transactional:
+		published, err := connector.PublishStaging(ctx, loadTable, config.Table, columns)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[INFO] Published staging table %s into %s (%d rows affected)\n", loadTable, config.Table, published)
+	}
+
+	if checkpoint != nil {
+		if err := checkpoint.remove(); err != nil {
+			return err
+		}
+	}
+
 	finalCount := atomic.LoadInt64(&rowCount)
 	elapsed := time.Since(startTime).Seconds()
-	fmt.Fprintf(os.Stderr, "[INFO] Import completed: %d rows in %.2f seconds (%.0f rows/sec)\n", 
-		finalCount, elapsed, float64(finalCount)/elapsed)
+	fmt.Fprintf(os.Stderr, "[INFO] Import completed: %d rows in %.2f seconds (%.0f rows/sec), %d rows rejected\n", 
+		finalCount, elapsed, float64(finalCount)/elapsed, rejected)
+	if rejected > 0 && config.RejectFile != "" {
+		fmt.Fprintf(os.Stderr, "[WARN] %d rejected rows written to %s\n", rejected, config.RejectFile)
+	}
 
 	return nil
 }
@@ -114,9 +462,28 @@ func ImportData(ctx context.Context, config *Config) error {
 type Importer interface {
 	Open() (columns []string, err error)
 	NextRow() ([]interface{}, error)
+	Skip(n int64) (int64, error) // Skip up to n rows, returning how many were skipped
+	Line() int64                 // Source line of the last row returned by NextRow
 	Close() error
 }
 
+// RowError is returned by NextRow for a row that could not be parsed.
+// Unlike other errors it does not end the import; reading can continue.
+type RowError struct {
+	Line   int64
+	Values []interface{} // Fields that could be read, if any
+	Raw    string        // Raw source text, if available
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
 // Config is imported from parent package
 type Config struct {
 	DSN           string
@@ -126,4 +493,123 @@ type Config struct {
 	BatchSize     int
 	Workers       int
 	ProgressEvery int
+	InsertMethod  string
+	OnConflict    string
+	ConflictKeys  []string
+	UpdateColumns []string
+	ColumnMapping *ColumnMapping
+
+	DisableTypeCoercion bool
+
+	CreateTable       bool
+	CreateTableSample int
+	CreateTableDryRun bool
+
+	RejectFile    string
+	MaxErrors     int64
+	MaxErrorRatio float64
+
+	CheckpointFile string
+	Resume         bool
+
+	Transactional bool
+	LoadStrategy  string
+	DeleteWhere   string
+
+	ParseWorkers int
+
+	InputCompression string
+	InputEncoding    string // EncodingAuto or a name known to textfile.LookupEncoding
+
+	Columns              []string
+	JSONLDiscovery       string
+	JSONLDiscoverySample int
+	JSONLNested          string
+	JSONLSeparator       string
+	JSONLMaxDepth        int
+	JSONLArrays          string
+	JSONPointer          string
+
+	XLSXSheet         string
+	XLSXSheetIndex    int
+	XLSXHeaderRow     int
+	XLSXDataStartRow  int
+	XLSXSheets        string
+	XLSXSheetColumn   string
+	XLSXTableTemplate string
+	XLSXReader        string
+	XLSXMaxSize       int64
+	XLSXTypedValues   bool
+
+	// CSV dialect; single characters are given as strings ("" = default)
+	CSVDelimiter    string
+	CSVQuote        string // A character or textfile.QuoteNone
+	CSVEscape       string
+	CSVComment      string
+	CSVNoHeader     bool
+	CSVSkipLines    int
+	CSVRagged       string
+	CSVKeepSpaces   bool
+	CSVStrictQuotes bool
+
+	appendRejects bool // Later sheets add to the reject file of earlier ones
+}
+
+// xlsxOptions collects the XLSX settings of config
+func xlsxOptions(config *Config) XLSXOptions {
+	return XLSXOptions{
+		Sheet:        config.XLSXSheet,
+		SheetIndex:   config.XLSXSheetIndex,
+		HeaderRow:    config.XLSXHeaderRow,
+		DataStartRow: config.XLSXDataStartRow,
+		Sheets:       config.XLSXSheets,
+		SheetColumn:  config.XLSXSheetColumn,
+		Reader:       config.XLSXReader,
+		MaxSize:      config.XLSXMaxSize,
+		Typed:        config.XLSXTypedValues,
+	}
+}
+
+// csvDialect builds the CSV dialect of the config. delimiter is the default
+// of the format.
+func csvDialect(config *Config, delimiter rune) CSVDialect {
+	return CSVDialect{
+		Delimiter:    textfile.Rune(config.CSVDelimiter, delimiter),
+		Quote:        textfile.QuoteRune(config.CSVQuote),
+		Escape:       config.CSVEscape,
+		Comment:      textfile.Rune(config.CSVComment, 0),
+		NoHeader:     config.CSVNoHeader,
+		SkipLines:    config.CSVSkipLines,
+		Ragged:       config.CSVRagged,
+		KeepSpaces:   config.CSVKeepSpaces,
+		StrictQuotes: config.CSVStrictQuotes,
+	}
+}
+
+// workTableName returns the name of a staging, shadow or retired copy of
+// table. The process id keeps concurrent imports into the same table apart.
+func workTableName(table, role string) string {
+	return fmt.Sprintf("%s_%s_%d", table, role, os.Getpid())
+}
+
+// checkConflictColumns verifies that conflict keys and update columns are
+// part of the imported column list
+func checkConflictColumns(config *Config, columns []string) error {
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+
+	for _, key := range config.ConflictKeys {
+		if !known[key] {
+			return fmt.Errorf("conflict key %q is not an imported column", key)
+		}
+	}
+	for _, col := range config.UpdateColumns {
+		if !known[col] {
+			return fmt.Errorf("update column %q is not an imported column", col)
+		}
+	}
+
+	return nil
 }
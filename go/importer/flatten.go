@@ -0,0 +1,375 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Nested value handling for JSONL input
+const (
+	NestedJSON    = "json"    // Nested objects and arrays are kept as JSON text
+	NestedFlatten = "flatten" // Nested objects become one column per path
+)
+
+// Array handling when flattening
+const (
+	ArraysJSON    = "json"    // Arrays are kept as JSON text
+	ArraysExplode = "explode" // Each array element becomes its own row
+)
+
+// maxExplodedRows is the most rows one object may explode into. Sibling
+// arrays multiply: two arrays of 1000 elements make a million rows.
+const maxExplodedRows = 10000
+
+// flattenError is raised by an object that is valid JSON but cannot be
+// turned into rows
+type flattenError struct {
+	msg string
+}
+
+func (e *flattenError) Error() string {
+	return e.msg
+}
+
+// collision reports two keys flattened into the same column
+func collision(path string) error {
+	return &flattenError{fmt.Sprintf("column %q is set by more than one key (flattened paths collide, choose another jsonl_separator)", path)}
+}
+
+// decodeError describes why a JSON value could not be turned into rows
+func decodeError(err error) error {
+	var flattenErr *flattenError
+	if errors.As(err, &flattenErr) {
+		return err
+	}
+	return fmt.Errorf("invalid JSON: %w", err)
+}
+
+// flattener turns JSON objects into flat column values. Values are decoded
+// lazily from raw JSON so anything kept as JSON keeps its key order.
+type flattener struct {
+	flatten   bool
+	explode   bool
+	separator string
+	maxDepth  int // Most path segments in a column name (0 = no limit)
+}
+
+// newFlattener builds a flattener from the JSONL options
+func newFlattener(opts JSONLOptions) *flattener {
+	separator := opts.Separator
+	if separator == "" {
+		separator = "_"
+	}
+	return &flattener{
+		flatten:   opts.Nested == NestedFlatten,
+		explode:   opts.Nested == NestedFlatten && opts.Arrays == ArraysExplode,
+		separator: separator,
+		maxDepth:  opts.MaxDepth,
+	}
+}
+
+// join appends a key to a column path
+func (f *flattener) join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + f.separator + key
+}
+
+// descends reports whether an object whose path has depth segments is
+// split into columns. The top-level object always is.
+func (f *flattener) descends(depth int) bool {
+	return depth == 0 || (f.flatten && (f.maxDepth <= 0 || depth < f.maxDepth))
+}
+
+// paths returns the column paths of a JSON object in document order
+func (f *flattener) paths(data []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	if err := f.walkObject(dec, "", 0, add); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// walkObject reports the paths of an object whose opening brace has been
+// read, up to and including its closing brace
+func (f *flattener) walkObject(dec *json.Decoder, prefix string, depth int, add func(string)) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := f.walkValue(dec, f.join(prefix, tok.(string)), depth+1, add); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// walkValue reports the paths of the next value in dec
+func (f *flattener) walkValue(dec *json.Decoder, path string, depth int, add func(string)) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	switch {
+	case !ok:
+		add(path)
+		return nil
+	case delim == '{' && f.descends(depth):
+		return f.walkObject(dec, path, depth, add)
+	case delim == '[' && f.explode:
+		// Elements share the array's path
+		empty := true
+		for dec.More() {
+			empty = false
+			if err := f.walkValue(dec, path, depth, add); err != nil {
+				return err
+			}
+		}
+		if empty {
+			add(path)
+		}
+		_, err := dec.Token()
+		return err
+	default:
+		add(path)
+		return skipValue(dec)
+	}
+}
+
+// skipValue reads past the rest of an object or array whose opening
+// delimiter has been read
+func skipValue(dec *json.Decoder) error {
+	for nesting := 1; nesting > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				nesting++
+			} else {
+				nesting--
+			}
+		}
+	}
+	return nil
+}
+
+// rows decodes a JSON object line into column values, one map per output
+// row. Without exploded arrays there is exactly one.
+func (f *flattener) rows(data []byte) ([]map[string]interface{}, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	if !f.explode {
+		values := make(map[string]interface{}, len(obj))
+		for key, raw := range obj {
+			if err := f.fill(key, 1, raw, values); err != nil {
+				return nil, err
+			}
+		}
+		return []map[string]interface{}{values}, nil
+	}
+
+	variants := []map[string]interface{}{{}}
+	for _, key := range sortedKeys(obj) {
+		expanded, err := f.expand(key, 1, obj[key])
+		if err != nil {
+			return nil, err
+		}
+		if variants, err = crossRows(variants, expanded); err != nil {
+			return nil, err
+		}
+	}
+	return variants, nil
+}
+
+// fill stores the column values of raw at path into values
+func (f *flattener) fill(path string, depth int, raw json.RawMessage, values map[string]interface{}) error {
+	if rawKind(raw) == '{' && f.descends(depth) {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return err
+		}
+		for key, child := range obj {
+			if err := f.fill(f.join(path, key), depth+1, child, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, ok := values[path]; ok {
+		return collision(path)
+	}
+	val, err := leafValue(raw)
+	if err != nil {
+		return err
+	}
+	values[path] = val
+	return nil
+}
+
+// expand returns the column values of raw at path for every row it
+// explodes into
+func (f *flattener) expand(path string, depth int, raw json.RawMessage) ([]map[string]interface{}, error) {
+	switch {
+	case rawKind(raw) == '{' && f.descends(depth):
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		variants := []map[string]interface{}{{}}
+		for _, key := range sortedKeys(obj) {
+			expanded, err := f.expand(f.join(path, key), depth+1, obj[key])
+			if err != nil {
+				return nil, err
+			}
+			if variants, err = crossRows(variants, expanded); err != nil {
+				return nil, err
+			}
+		}
+		return variants, nil
+
+	case rawKind(raw) == '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return nil, err
+		}
+		if len(elems) == 0 {
+			// Keep the row, with nothing in the array's columns
+			return []map[string]interface{}{{path: nil}}, nil
+		}
+		var variants []map[string]interface{}
+		for _, elem := range elems {
+			expanded, err := f.expand(path, depth, elem)
+			if err != nil {
+				return nil, err
+			}
+			variants = append(variants, expanded...)
+			if len(variants) > maxExplodedRows {
+				return nil, tooManyRows()
+			}
+		}
+		return variants, nil
+
+	default:
+		val, err := leafValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]interface{}{{path: val}}, nil
+	}
+}
+
+// sortedKeys returns the keys of obj in a fixed order, so exploded rows
+// come out the same way on every run
+func sortedKeys(obj map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// crossRows combines every row in a with every row in b. The rows of a and
+// b hold different keys, so a column set on both sides is a collision.
+func crossRows(a, b []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(b) == 1 {
+		for _, row := range a {
+			if err := mergeRow(row, b[0]); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	if len(a)*len(b) > maxExplodedRows {
+		return nil, tooManyRows()
+	}
+
+	out := make([]map[string]interface{}, 0, len(a)*len(b))
+	for _, left := range a {
+		for _, right := range b {
+			row := make(map[string]interface{}, len(left)+len(right))
+			for k, v := range left {
+				row[k] = v
+			}
+			if err := mergeRow(row, right); err != nil {
+				return nil, err
+			}
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+// mergeRow adds the values of src to row, which must not have them yet
+func mergeRow(row, src map[string]interface{}) error {
+	for k, v := range src {
+		if _, ok := row[k]; ok {
+			return collision(k)
+		}
+		row[k] = v
+	}
+	return nil
+}
+
+// tooManyRows reports an object exploding into more than maxExplodedRows
+func tooManyRows() error {
+	return &flattenError{fmt.Sprintf("object explodes into more than %d rows; sibling arrays multiply, keep the others as JSON (jsonl_arrays json)", maxExplodedRows)}
+}
+
+// leafValue decodes a scalar, or returns objects and arrays as JSON text
+func leafValue(raw json.RawMessage) (interface{}, error) {
+	switch rawKind(raw) {
+	case '{', '[':
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return nil, err
+		}
+		return compact.String(), nil
+	}
+
+	var val interface{}
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// rawKind returns the first significant byte of a raw JSON value
+func rawKind(raw json.RawMessage) byte {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
@@ -0,0 +1,395 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/datamill/data-engine/go/textfile"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Input compression
+const (
+	CompressionAuto  = "auto" // From the extension, then the magic bytes
+	CompressionNone  = "none"
+	CompressionGzip  = "gzip"
+	CompressionZstd  = "zstd"
+	CompressionBzip2 = "bzip2"
+	CompressionXz    = "xz"
+	CompressionZip   = "zip" // Archive of one or more data files, read in order
+)
+
+// EncodingAuto reads UTF-8, or UTF-16 when a byte order mark says so
+const EncodingAuto = "auto"
+
+// compressionExts maps file extensions to the compression they stand for
+var compressionExts = map[string]string{
+	".gz":   CompressionGzip,
+	".gzip": CompressionGzip,
+	".zst":  CompressionZstd,
+	".zstd": CompressionZstd,
+	".bz2":  CompressionBzip2,
+	".xz":   CompressionXz,
+	".zip":  CompressionZip,
+}
+
+// DetectCompression returns the compression of a file from its extension,
+// or failing that its magic bytes. A zip archive holding an XLSX workbook
+// is not compressed input.
+func DetectCompression(filePath string) (string, error) {
+	if compression, ok := compressionExts[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return compression, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, 6)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return CompressionGzip, nil
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return CompressionZstd, nil
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return CompressionBzip2, nil
+	case bytes.HasPrefix(header, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return CompressionXz, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		archive, err := zip.OpenReader(filePath)
+		if err != nil {
+			return CompressionNone, nil
+		}
+		defer archive.Close()
+		for _, f := range archive.File {
+			if f.Name == "[Content_Types].xml" {
+				return CompressionNone, nil // Office document
+			}
+		}
+		return CompressionZip, nil
+	}
+	return CompressionNone, nil
+}
+
+// InputName returns the name the input format is detected from: the path
+// without its compression extension, so data.csv.gz gives data.csv, or the
+// first data file of a zip archive
+func InputName(filePath, compression string) (string, error) {
+	if compression == CompressionZip {
+		archive, err := zip.OpenReader(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		defer archive.Close()
+		members := dataMembers(archive)
+		if len(members) == 0 {
+			return "", fmt.Errorf("zip archive %s holds no data files", filePath)
+		}
+		return members[0].Name, nil
+	}
+
+	if _, ok := compressionExts[strings.ToLower(filepath.Ext(filePath))]; ok && compression != CompressionNone {
+		return strings.TrimSuffix(filePath, filepath.Ext(filePath)), nil
+	}
+	return filePath, nil
+}
+
+// PeekInput returns up to the first n decompressed and decoded bytes of a
+// file
+func PeekInput(filePath, compression, encoding string, n int) ([]byte, error) {
+	in, err := OpenInput(filePath, compression, encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	data := make([]byte, n)
+	read, err := io.ReadFull(in, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return data[:read], nil
+}
+
+// Input reads a file through a streaming decompressor and decodes it to
+// UTF-8. A zip archive is read one data file at a time; Read stops at the
+// end of each. A byte order mark at the start of a file is skipped.
+type Input struct {
+	path        string
+	compression string
+	encoding    encoding.Encoding // nil for auto
+	file        *os.File
+	archive     *zip.ReadCloser
+	members     []*zip.File // Data files still to read after the current one
+	member      string
+	stream      io.ReadCloser // Decompressor, nil for plain files
+	reader      io.Reader
+	start       int64 // Bytes of byte order mark skipped in a plain file
+	bom         string
+	decoded     bool // Read through a decoder, so offsets do not match the file
+}
+
+// Byte order marks
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// OpenInput opens a file for reading with the given compression and
+// character encoding, EncodingAuto or a name known to textfile.LookupEncoding
+func OpenInput(filePath, compression, encoding string) (*Input, error) {
+	in := &Input{path: filePath, compression: compression}
+	if encoding != "" && encoding != EncodingAuto {
+		enc, err := textfile.LookupEncoding(encoding)
+		if err != nil {
+			return nil, err
+		}
+		in.encoding = enc
+	}
+	if err := in.open(); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// open opens the file and positions the input on its start
+func (in *Input) open() error {
+	if in.compression == CompressionZip {
+		archive, err := zip.OpenReader(in.path)
+		if err != nil {
+			return fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		in.archive = archive
+		in.members = dataMembers(archive)
+		if len(in.members) == 0 {
+			archive.Close()
+			return fmt.Errorf("zip archive %s holds no data files", in.path)
+		}
+		_, err = in.NextMember()
+		return err
+	}
+
+	file, err := os.Open(in.path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	in.file = file
+
+	buffered := bufio.NewReaderSize(file, 256*1024)
+	switch in.compression {
+	case CompressionGzip:
+		in.stream, err = gzip.NewReader(buffered)
+	case CompressionZstd:
+		var dec *zstd.Decoder
+		dec, err = zstd.NewReader(buffered)
+		if err == nil {
+			in.stream = dec.IOReadCloser()
+		}
+	case CompressionBzip2:
+		in.stream = io.NopCloser(bzip2.NewReader(buffered))
+	case CompressionXz:
+		var dec *xz.Reader
+		dec, err = xz.NewReader(buffered)
+		in.stream = io.NopCloser(dec)
+	default:
+		in.reader = file
+		if err := in.startFile(); err != nil {
+			file.Close()
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open %s stream: %w", in.compression, err)
+	}
+	in.reader = in.stream
+	if err := in.startFile(); err != nil {
+		in.Close()
+		return err
+	}
+	return nil
+}
+
+// startFile moves past a byte order mark at the start of the current file
+// and decodes the file to UTF-8 unless it is UTF-8 already. A byte order
+// mark takes precedence over the configured encoding.
+func (in *Input) startFile() error {
+	in.start, in.decoded = 0, false
+	var head []byte
+	buffered, isBuffered := in.reader.(*bufio.Reader)
+	if file := in.File(); file != nil {
+		head = make([]byte, 3)
+		n, _ := file.ReadAt(head, 0)
+		head = head[:n]
+	} else {
+		if !isBuffered {
+			buffered = bufio.NewReaderSize(in.reader, 64*1024)
+			in.reader = buffered
+		}
+		head, _ = buffered.Peek(3)
+	}
+
+	enc := in.encoding
+	var bom []byte
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		in.bom, bom, enc = "UTF-8", bomUTF8, unicode.UTF8
+	case bytes.HasPrefix(head, bomUTF16LE):
+		in.bom, bom, enc = "UTF-16LE", bomUTF16LE, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.HasPrefix(head, bomUTF16BE):
+		in.bom, bom, enc = "UTF-16BE", bomUTF16BE, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	default:
+		in.bom = ""
+	}
+	if in.bom != "" && in.encoding != nil {
+		if name, _ := ianaindex.IANA.Name(in.encoding); !strings.HasPrefix(in.bom, name) {
+			fmt.Fprintf(os.Stderr, "[WARN] %s starts with a %s byte order mark, reading it as %s instead of %s\n", in.path, in.bom, in.bom, name)
+		}
+	}
+
+	if len(bom) > 0 {
+		if file := in.File(); file != nil {
+			in.start = int64(len(bom))
+			if _, err := file.Seek(in.start, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to skip byte order mark: %w", err)
+			}
+		} else {
+			buffered.Discard(len(bom))
+		}
+	}
+
+	if enc != nil && enc != unicode.UTF8 {
+		in.reader = transform.NewReader(in.reader, enc.NewDecoder())
+		in.decoded = true
+	}
+	return nil
+}
+
+// BOM returns the encoding named by the byte order mark skipped at the
+// start of the current file (UTF-8, UTF-16LE or UTF-16BE), "" if there was
+// none
+func (in *Input) BOM() string {
+	return in.bom
+}
+
+// Start returns the offset in the file of the first byte returned by Read,
+// past any byte order mark. Only meaningful when File is not nil.
+func (in *Input) Start() int64 {
+	return in.start
+}
+
+// dataMembers returns the files of an archive, leaving out directories and
+// hidden files such as macOS metadata
+func dataMembers(archive *zip.ReadCloser) []*zip.File {
+	var members []*zip.File
+	for _, f := range archive.File {
+		base := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		members = append(members, f)
+	}
+	return members
+}
+
+// Read reads decompressed data from the current file
+func (in *Input) Read(p []byte) (int, error) {
+	return in.reader.Read(p)
+}
+
+// NextMember moves to the next data file of a zip archive, reporting false
+// when there are no more
+func (in *Input) NextMember() (bool, error) {
+	if len(in.members) == 0 {
+		return false, nil
+	}
+	if in.stream != nil {
+		in.stream.Close()
+	}
+
+	member := in.members[0]
+	in.members = in.members[1:]
+	stream, err := member.Open()
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s from zip archive: %w", member.Name, err)
+	}
+	in.member = member.Name
+	in.stream = stream
+	in.reader = bufio.NewReaderSize(stream, 256*1024)
+	fmt.Fprintf(os.Stderr, "[INFO] Reading archive member: %s\n", member.Name)
+	if err := in.startFile(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Members returns the number of data files not yet started
+func (in *Input) Members() int {
+	return len(in.members)
+}
+
+// Member returns the name of the zip archive file being read
+func (in *Input) Member() string {
+	return in.member
+}
+
+// File returns the underlying file when the input is neither compressed
+// nor decoded, for readers that need random access; nil otherwise
+func (in *Input) File() *os.File {
+	if in.stream != nil || in.archive != nil || in.decoded {
+		return nil
+	}
+	return in.file
+}
+
+// Rewind starts the input again from the beginning
+func (in *Input) Rewind() error {
+	if file := in.File(); file != nil {
+		if _, err := file.Seek(in.start, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind file: %w", err)
+		}
+		return nil
+	}
+	in.Close()
+	return in.open()
+}
+
+// Close closes the decompressor and the file
+func (in *Input) Close() error {
+	if in.stream != nil {
+		in.stream.Close()
+		in.stream = nil
+	}
+	if in.archive != nil {
+		err := in.archive.Close()
+		in.archive = nil
+		return err
+	}
+	if in.file != nil {
+		err := in.file.Close()
+		in.file = nil
+		return err
+	}
+	return nil
+}
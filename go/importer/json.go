@@ -0,0 +1,372 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSONImporter streams the objects of a JSON array, either the top-level
+// value or one found through a JSON pointer. Elements are decoded one at a
+// time, so memory does not grow with the size of the file.
+type JSONImporter struct {
+	*objectRows
+	filePath    string
+	pointer     string // RFC 6901 pointer to the array ("" = top level)
+	compression string
+	encoding    string
+	input       *Input
+	lines       *lineCounter
+	dec         *json.Decoder
+	pending     [][]interface{} // Rows decoded but not yet returned
+	line        int64
+}
+
+// NewJSONImporter creates a new JSON array importer. compression is one of
+// the Compression* constants other than auto; encoding is EncodingAuto or
+// a name known to textfile.LookupEncoding.
+func NewJSONImporter(filePath, pointer, compression, encoding string, opts JSONLOptions) *JSONImporter {
+	return &JSONImporter{
+		objectRows:  newObjectRows(opts),
+		filePath:    filePath,
+		pointer:     pointer,
+		compression: compression,
+		encoding:    encoding,
+	}
+}
+
+// Open finds the array and discovers the columns from its elements
+func (j *JSONImporter) Open() ([]string, error) {
+	if err := j.openArray(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(j.opts.Columns) > 0:
+		j.setColumns(j.opts.Columns)
+	case j.opts.Discovery == DiscoverSample || j.opts.Discovery == DiscoverFull:
+		limit := 0
+		if j.opts.Discovery == DiscoverSample {
+			limit = j.opts.Sample
+		}
+		if err := j.discoverColumns(limit); err != nil {
+			j.Close()
+			return nil, err
+		}
+	default:
+		if err := j.readFirstElement(); err != nil {
+			j.Close()
+			return nil, err
+		}
+	}
+
+	if len(j.columns) == 0 {
+		j.Close()
+		return nil, fmt.Errorf("no JSON objects found to discover columns")
+	}
+
+	return j.columns, nil
+}
+
+// openArray opens the file and positions the decoder on the first element
+// of the array
+func (j *JSONImporter) openArray() error {
+	input, err := OpenInput(j.filePath, j.compression, j.encoding)
+	if err != nil {
+		return err
+	}
+	j.input = input
+
+	if err := j.startArray(); err != nil {
+		input.Close()
+		return err
+	}
+	return nil
+}
+
+// startArray positions a new decoder over the current input on the first
+// element of the array
+func (j *JSONImporter) startArray() error {
+	j.lines = &lineCounter{r: bufio.NewReaderSize(j.input, 256*1024)}
+	j.dec = json.NewDecoder(j.lines)
+	j.line = 0
+
+	if err := seekPointer(j.dec, j.pointer); err != nil {
+		return err
+	}
+
+	tok, err := j.dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		if j.pointer == "" {
+			return fmt.Errorf("top-level JSON value is not an array; set json_pointer to the array, e.g. /data")
+		}
+		return fmt.Errorf("value at json_pointer %s is not an array", j.pointer)
+	}
+
+	return nil
+}
+
+// readFirstElement takes the columns from the first object, whose rows are
+// kept to be returned by the first NextRow calls
+func (j *JSONImporter) readFirstElement() error {
+	raw, err := j.nextElement()
+	if err != nil {
+		if err.Error() == "EOF" {
+			return nil
+		}
+		return err
+	}
+
+	paths, err := j.flatten.paths(raw)
+	if err != nil {
+		return fmt.Errorf("invalid first element on line %d: %w", j.line, err)
+	}
+	j.setColumns(paths)
+
+	rows, err := j.decode(raw, j.line)
+	if err != nil {
+		return fmt.Errorf("first element on line %d: %w", j.line, decodeError(err))
+	}
+	j.pending = rows
+	return nil
+}
+
+// discoverColumns collects the keys of the first limit elements (all of
+// them if limit is 0), then reopens the array
+func (j *JSONImporter) discoverColumns(limit int) error {
+	var columns []string
+	seen := make(map[string]bool)
+	for n := 0; limit <= 0 || n < limit; n++ {
+		raw, err := j.nextElement()
+		if err != nil {
+			if err.Error() == "EOF" {
+				break
+			}
+			return err
+		}
+		keys, err := j.flatten.paths(raw)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	j.setColumns(columns)
+
+	j.input.Close()
+	return j.openArray()
+}
+
+// nextMember moves on to the array in the next file of a zip archive. Line
+// numbers start again in each file.
+func (j *JSONImporter) nextMember() (bool, error) {
+	if j.input == nil {
+		return false, nil
+	}
+	ok, err := j.input.NextMember()
+	if !ok || err != nil {
+		return false, err
+	}
+	if err := j.startArray(); err != nil {
+		return false, fmt.Errorf("%s: %w", j.input.Member(), err)
+	}
+	return true, nil
+}
+
+// nextElement returns the raw text of the next array element
+func (j *JSONImporter) nextElement() (json.RawMessage, error) {
+	for !j.dec.More() {
+		more, err := j.nextMember()
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return nil, fmt.Errorf("EOF")
+		}
+	}
+
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		// The decoder cannot resynchronize, so this ends the import
+		return nil, fmt.Errorf("invalid JSON after line %d: %w", j.line, err)
+	}
+
+	// The element ends at the decoder offset and starts len(raw) before it
+	j.line = j.lines.lineAt(j.dec.InputOffset() - int64(len(raw)))
+	return raw, nil
+}
+
+// NextRow returns the next row. An element with exploded arrays yields
+// several rows, all reporting the same line.
+func (j *JSONImporter) NextRow() ([]interface{}, error) {
+	for len(j.pending) == 0 {
+		raw, err := j.nextElement()
+		if err != nil {
+			return nil, err
+		}
+
+		if rawKind(raw) != '{' {
+			return nil, &RowError{Line: j.line, Raw: string(raw), Err: fmt.Errorf("array element is not an object")}
+		}
+		rows, err := j.decode(raw, j.line)
+		if err != nil {
+			return nil, &RowError{Line: j.line, Raw: string(raw), Err: decodeError(err)}
+		}
+		j.pending = rows
+	}
+
+	row := j.pending[0]
+	j.pending = j.pending[1:]
+	return row, nil
+}
+
+// Skip moves past n rows. Elements are skipped without converting them, so
+// each element counts as one row.
+func (j *JSONImporter) Skip(n int64) (int64, error) {
+	var skipped int64
+	for skipped < n && len(j.pending) > 0 {
+		j.pending = j.pending[1:]
+		skipped++
+	}
+	for skipped < n {
+		if _, err := j.nextElement(); err != nil {
+			if err.Error() == "EOF" {
+				break
+			}
+			return skipped, err
+		}
+		skipped++
+	}
+	return skipped, nil
+}
+
+// Line returns the line on which the last element returned by NextRow starts
+func (j *JSONImporter) Line() int64 {
+	return j.line
+}
+
+// Close closes the JSON file
+func (j *JSONImporter) Close() error {
+	if j.input != nil {
+		return j.input.Close()
+	}
+	return nil
+}
+
+// seekPointer advances dec to the value a JSON pointer refers to, skipping
+// everything before it without decoding
+func seekPointer(dec *json.Decoder, pointer string) error {
+	if pointer == "" {
+		return nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return fmt.Errorf("invalid json_pointer %q: must start with /", pointer)
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		delim, _ := tok.(json.Delim)
+
+		switch delim {
+		case '{':
+			found := false
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return fmt.Errorf("invalid JSON: %w", err)
+				}
+				if key.(string) == token {
+					found = true
+					break
+				}
+				if err := skipNext(dec); err != nil {
+					return err
+				}
+			}
+			if !found {
+				return fmt.Errorf("json_pointer %s: key %q not found", pointer, token)
+			}
+
+		case '[':
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 {
+				return fmt.Errorf("json_pointer %s: %q is not an array index", pointer, token)
+			}
+			for i := 0; i < index; i++ {
+				if !dec.More() {
+					return fmt.Errorf("json_pointer %s: index %d out of range", pointer, index)
+				}
+				if err := skipNext(dec); err != nil {
+					return err
+				}
+			}
+			if !dec.More() {
+				return fmt.Errorf("json_pointer %s: index %d out of range", pointer, index)
+			}
+
+		default:
+			return fmt.Errorf("json_pointer %s: cannot look up %q in a scalar value", pointer, token)
+		}
+	}
+
+	return nil
+}
+
+// skipNext reads past the next value in dec
+func skipNext(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); ok && (delim == '{' || delim == '[') {
+		if err := skipValue(dec); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+	return nil
+}
+
+// lineCounter remembers where newlines are in what has been read, so a
+// byte offset reported by a decoder can be turned into a line number.
+// Offsets must be looked up in increasing order.
+type lineCounter struct {
+	r        io.Reader
+	offset   int64   // Bytes read so far
+	newlines []int64 // Newline offsets not yet behind a looked up offset
+	passed   int64   // Newlines behind the last looked up offset
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.newlines = append(c.newlines, c.offset+int64(i))
+		}
+	}
+	c.offset += int64(n)
+	return n, err
+}
+
+// lineAt returns the line holding the byte at offset
+func (c *lineCounter) lineAt(offset int64) int64 {
+	for len(c.newlines) > 0 && c.newlines[0] < offset {
+		c.passed++
+		c.newlines = c.newlines[1:]
+	}
+	return c.passed + 1
+}
@@ -2,107 +2,344 @@ package importer
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sync"
 )
 
+// MaxLineSize is the longest JSONL line that can be read
+const MaxLineSize = 1024 * 1024 // 1MB
+
+// Column discovery modes for JSONL input
+const (
+	DiscoverFirstLine = "first-line" // Keys of the first object
+	DiscoverSample    = "sample"     // Union of keys over the first lines
+	DiscoverFull      = "full"       // Union of keys over the whole file
+)
+
+// DefaultDiscoverySample is the number of lines scanned by DiscoverSample
+const DefaultDiscoverySample = 1000
+
+// JSONLOptions controls how JSONL and JSON objects are turned into rows
+type JSONLOptions struct {
+	Discovery string   // One of the Discover* constants ("" = first-line)
+	Sample    int      // Lines scanned by DiscoverSample
+	Columns   []string // Fixed column list; skips discovery when set
+
+	Nested    string // One of the Nested* constants ("" = json)
+	Separator string // Joins the keys of a flattened path ("" = "_")
+	MaxDepth  int    // Most keys in a flattened path (0 = no limit)
+	Arrays    string // One of the Arrays* constants ("" = json)
+}
+
+// objectRows turns JSON objects into rows over a fixed column list
+type objectRows struct {
+	opts    JSONLOptions
+	flatten *flattener
+	columns []string
+	known   map[string]bool // Columns, to spot keys discovery missed
+	unknown sync.Once       // Warns about the first missed key
+}
+
+// newObjectRows creates the shared row builder for JSON input
+func newObjectRows(opts JSONLOptions) *objectRows {
+	return &objectRows{opts: opts, flatten: newFlattener(opts)}
+}
+
+// setColumns fixes the column list rows are built from
+func (o *objectRows) setColumns(columns []string) {
+	o.columns = columns
+	o.known = make(map[string]bool, len(columns))
+	for _, col := range columns {
+		o.known[col] = true
+	}
+}
+
+// decode turns one JSON object into rows in column order
+func (o *objectRows) decode(data []byte, line int64) ([][]interface{}, error) {
+	objects, err := o.flatten.rows(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys outside the discovered columns are dropped; say so once. A
+	// fixed column list drops them on purpose.
+	if len(o.opts.Columns) == 0 && o.opts.Discovery != DiscoverFull {
+		for key, val := range objects[0] {
+			if val != nil && !o.known[key] {
+				o.unknown.Do(func() {
+					fmt.Fprintf(os.Stderr, "[WARN] Line %d has key %q that is not a discovered column; keys missing from the discovery scan are dropped (see jsonl_discovery)\n", line, key)
+				})
+				break
+			}
+		}
+	}
+
+	// Convert to rows in column order
+	rows := make([][]interface{}, len(objects))
+	for r, obj := range objects {
+		row := make([]interface{}, len(o.columns))
+		for i, col := range o.columns {
+			row[i] = obj[col]
+		}
+		rows[r] = row
+	}
+
+	return rows, nil
+}
+
 // JSONLImporter handles JSONL (newline-delimited JSON) file imports
 type JSONLImporter struct {
-	filePath string
-	file     *os.File
-	scanner  *bufio.Scanner
-	columns  []string
-	firstRow map[string]interface{}
+	*objectRows // Shared with split readers
+	filePath    string
+	compression string
+	encoding    string
+	input       *Input
+	scanner     *bufio.Scanner
+	pending     [][]interface{} // Rows decoded but not yet returned
+	line        int64
 }
 
-// NewJSONLImporter creates a new JSONL importer
-func NewJSONLImporter(filePath string) *JSONLImporter {
+// NewJSONLImporter creates a new JSONL importer. compression is one of the
+// Compression* constants other than auto; encoding is EncodingAuto or a
+// name known to textfile.LookupEncoding.
+func NewJSONLImporter(filePath, compression, encoding string, opts JSONLOptions) *JSONLImporter {
 	return &JSONLImporter{
-		filePath: filePath,
+		objectRows:  newObjectRows(opts),
+		filePath:    filePath,
+		compression: compression,
+		encoding:    encoding,
 	}
 }
 
-// Open opens the JSONL file and reads the first line to detect columns
+// Open opens the JSONL file and discovers the columns. Columns are ordered
+// by the first appearance of each key, so runs over the same file agree.
 func (j *JSONLImporter) Open() ([]string, error) {
-	file, err := os.Open(j.filePath)
+	input, err := OpenInput(j.filePath, j.compression, j.encoding)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, err
 	}
-	j.file = file
+	j.input = input
+	j.scanner = newLineScanner(input)
 
-	// Create scanner for line-by-line reading
-	j.scanner = bufio.NewScanner(file)
-	
-	// Increase buffer size for large lines
-	const maxCapacity = 1024 * 1024 // 1MB
-	buf := make([]byte, maxCapacity)
-	j.scanner.Buffer(buf, maxCapacity)
+	switch {
+	case len(j.opts.Columns) > 0:
+		j.setColumns(j.opts.Columns)
+	case j.opts.Discovery == DiscoverSample || j.opts.Discovery == DiscoverFull:
+		limit := 0
+		if j.opts.Discovery == DiscoverSample {
+			limit = j.opts.Sample
+		}
+		if err := j.discoverColumns(limit); err != nil {
+			j.input.Close()
+			return nil, err
+		}
+		if len(j.columns) == 0 {
+			j.input.Close()
+			return nil, fmt.Errorf("no JSON objects found to discover columns")
+		}
+	default:
+		if err := j.readFirstLine(); err != nil {
+			j.input.Close()
+			return nil, err
+		}
+	}
 
-	// Read first line to detect columns
+	return j.columns, nil
+}
+
+// readFirstLine takes the columns from the first object, whose rows are
+// kept to be returned by the first NextRow calls
+func (j *JSONLImporter) readFirstLine() error {
 	if !j.scanner.Scan() {
-		j.file.Close()
-		return nil, fmt.Errorf("empty file or read error")
+		return fmt.Errorf("empty file or read error")
+	}
+	j.line = 1
+
+	paths, err := j.flatten.paths(j.scanner.Bytes())
+	if err != nil {
+		return fmt.Errorf("invalid JSON on first line: %w", err)
 	}
+	j.setColumns(paths)
+
+	rows, err := j.decode(j.scanner.Bytes(), j.line)
+	if err != nil {
+		return fmt.Errorf("first line: %w", decodeError(err))
+	}
+	j.pending = rows
+	return nil
+}
 
-	var firstObj map[string]interface{}
-	if err := json.Unmarshal(j.scanner.Bytes(), &firstObj); err != nil {
-		j.file.Close()
-		return nil, fmt.Errorf("invalid JSON on first line: %w", err)
+// discoverColumns collects the keys of the first limit lines (all lines if
+// limit is 0), then rewinds the file. Invalid lines are left for NextRow
+// to report. A zip archive is scanned through all its files.
+func (j *JSONLImporter) discoverColumns(limit int) error {
+	var columns []string
+	seen := make(map[string]bool)
+	for lines := 0; limit <= 0 || lines < limit; {
+		if !j.scanner.Scan() {
+			if err := j.scanner.Err(); err != nil {
+				return fmt.Errorf("scanner error: %w", err)
+			}
+			more, err := j.nextMember()
+			if err != nil {
+				return err
+			}
+			if !more {
+				break
+			}
+			continue
+		}
+		lines++
+
+		keys, err := j.flatten.paths(j.scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
 	}
+	j.setColumns(columns)
 
-	// Extract column names from first object
-	j.columns = make([]string, 0, len(firstObj))
-	for key := range firstObj {
-		j.columns = append(j.columns, key)
+	if err := j.input.Rewind(); err != nil {
+		return err
 	}
+	j.scanner = newLineScanner(j.input)
+	j.line = 0
+	return nil
+}
 
-	// Store first row to be returned on first NextRow call
-	j.firstRow = firstObj
+// nextMember moves on to the next file of a zip archive. Line numbers
+// start again in each file.
+func (j *JSONLImporter) nextMember() (bool, error) {
+	if j.input == nil {
+		return false, nil
+	}
+	ok, err := j.input.NextMember()
+	if !ok || err != nil {
+		return false, err
+	}
+	j.scanner = newLineScanner(j.input)
+	j.line = 0
+	return true, nil
+}
 
-	return j.columns, nil
+// newLineScanner returns a scanner for lines up to MaxLineSize
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, MaxLineSize), MaxLineSize)
+	return scanner
 }
 
-// NextRow reads the next row from the JSONL file
+// NextRow reads the next row from the JSONL file. A line with exploded
+// arrays yields several rows, all reporting the same line.
 func (j *JSONLImporter) NextRow() ([]interface{}, error) {
-	var obj map[string]interface{}
-
-	// Return first row if available
-	if j.firstRow != nil {
-		obj = j.firstRow
-		j.firstRow = nil
-	} else {
+	for len(j.pending) == 0 {
 		// Read next line
 		if !j.scanner.Scan() {
 			if err := j.scanner.Err(); err != nil {
 				return nil, fmt.Errorf("scanner error: %w", err)
 			}
-			return nil, fmt.Errorf("EOF")
+			more, err := j.nextMember()
+			if err != nil {
+				return nil, err
+			}
+			if !more {
+				return nil, fmt.Errorf("EOF")
+			}
+			continue
 		}
+		j.line++
 
 		// Parse JSON
-		if err := json.Unmarshal(j.scanner.Bytes(), &obj); err != nil {
-			return nil, fmt.Errorf("invalid JSON: %w", err)
+		rows, err := j.decode(j.scanner.Bytes(), j.line)
+		if err != nil {
+			return nil, &RowError{Line: j.line, Raw: j.scanner.Text(), Err: decodeError(err)}
+		}
+		j.pending = rows
+	}
+
+	row := j.pending[0]
+	j.pending = j.pending[1:]
+	return row, nil
+}
+
+// Skip moves past n rows. Lines are skipped without parsing them, so each
+// line counts as one row.
+func (j *JSONLImporter) Skip(n int64) (int64, error) {
+	var skipped int64
+	for skipped < n && len(j.pending) > 0 {
+		j.pending = j.pending[1:]
+		skipped++
+	}
+	for skipped < n {
+		if !j.scanner.Scan() {
+			if err := j.scanner.Err(); err != nil {
+				return skipped, fmt.Errorf("scanner error: %w", err)
+			}
+			more, err := j.nextMember()
+			if err != nil {
+				return skipped, err
+			}
+			if !more {
+				break
+			}
+			continue
 		}
+		j.line++
+		skipped++
 	}
+	return skipped, nil
+}
 
-	// Convert to row in column order
-	row := make([]interface{}, len(j.columns))
-	for i, col := range j.columns {
-		if val, ok := obj[col]; ok {
-			row[i] = val
-		} else {
-			row[i] = nil
+// split divides the file into byte ranges of whole lines with a reader
+// each. Readers share the open file, which is read with ReadAt.
+func (j *JSONLImporter) split(n int) ([]rowReader, error) {
+	file := j.input.File()
+	if file == nil {
+		fmt.Fprintf(os.Stderr, "[WARN] parse_workers ignored, compressed or re-encoded input is read by a single reader\n")
+		return []rowReader{j}, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to split input: %w", err)
+	}
+
+	// The first line is data as well, so ranges cover the whole file after
+	// any byte order mark
+	scanner := newRecordScanner('\n', false, false)
+	ranges, err := scanner.splitRanges(file, j.input.Start(), info.Size(), 0, n)
+	if err != nil {
+		return nil, err
+	}
+
+	readers := make([]rowReader, len(ranges))
+	for i, r := range ranges {
+		readers[i] = &JSONLImporter{
+			objectRows: j.objectRows,
+			filePath:   j.filePath,
+			scanner:    newLineScanner(io.NewSectionReader(file, r.start, r.end-r.start)),
+			line:       r.lines,
 		}
 	}
+	return readers, nil
+}
 
-	return row, nil
+// Line returns the line number of the last row returned by NextRow
+func (j *JSONLImporter) Line() int64 {
+	return j.line
 }
 
 // Close closes the JSONL file
 func (j *JSONLImporter) Close() error {
-	if j.file != nil {
-		return j.file.Close()
+	if j.input != nil {
+		return j.input.Close()
 	}
 	return nil
 }
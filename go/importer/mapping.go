@@ -0,0 +1,152 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ColumnMapping describes how file columns map onto table columns
+type ColumnMapping struct {
+	Rename    map[string]string      `json:"rename"`    // Source column -> target column
+	Drop      []string               `json:"drop"`      // Source columns to leave out
+	Order     []string               `json:"order"`     // Target columns to put first, in this order
+	Constants map[string]interface{} `json:"constants"` // Target column -> value used for every row
+}
+
+// columnMapper projects source rows onto the mapped target columns
+type columnMapper struct {
+	columns []string      // Target column names
+	sources []int         // Source index per target column, -1 for constants
+	values  []interface{} // Constant value per target column
+}
+
+// newColumnMapper validates the mapping against the file header and builds
+// the projection. Target columns follow Order first, then the remaining
+// source columns in file order, then constants sorted by name.
+func newColumnMapper(mapping *ColumnMapping, header []string) (*columnMapper, error) {
+	index := make(map[string]int, len(header))
+	duplicates := make(map[string]bool)
+	for i, col := range header {
+		if _, ok := index[col]; ok {
+			duplicates[col] = true
+			continue
+		}
+		index[col] = i
+	}
+
+	lookup := func(option, col string) (int, error) {
+		i, ok := index[col]
+		if !ok {
+			return 0, fmt.Errorf("column_mapping.%s: source column %q not found in header", option, col)
+		}
+		if duplicates[col] {
+			return 0, fmt.Errorf("column_mapping.%s: source column %q appears more than once in header", option, col)
+		}
+		return i, nil
+	}
+
+	dropped := make(map[int]bool, len(mapping.Drop))
+	for _, col := range mapping.Drop {
+		i, err := lookup("drop", col)
+		if err != nil {
+			return nil, err
+		}
+		dropped[i] = true
+	}
+
+	targets := make([]string, len(header))
+	copy(targets, header)
+	for source, target := range mapping.Rename {
+		i, err := lookup("rename", source)
+		if err != nil {
+			return nil, err
+		}
+		if dropped[i] {
+			return nil, fmt.Errorf("column_mapping: column %q is both renamed and dropped", source)
+		}
+		targets[i] = target
+	}
+
+	// Collect target columns in file order
+	type target struct {
+		source int
+		value  interface{}
+	}
+	byName := make(map[string]target, len(header)+len(mapping.Constants))
+	var natural []string
+	for i, name := range targets {
+		if dropped[i] {
+			continue
+		}
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("column_mapping: target column %q is produced more than once", name)
+		}
+		byName[name] = target{source: i}
+		natural = append(natural, name)
+	}
+
+	constNames := make([]string, 0, len(mapping.Constants))
+	for name := range mapping.Constants {
+		constNames = append(constNames, name)
+	}
+	sort.Strings(constNames)
+	for _, name := range constNames {
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("column_mapping.constants: target column %q is already mapped from the file", name)
+		}
+		byName[name] = target{source: -1, value: mapping.Constants[name]}
+		natural = append(natural, name)
+	}
+
+	// Apply the requested order, then everything else
+	m := &columnMapper{}
+	placed := make(map[string]bool, len(byName))
+	add := func(name string) {
+		t := byName[name]
+		m.columns = append(m.columns, name)
+		m.sources = append(m.sources, t.source)
+		m.values = append(m.values, t.value)
+		placed[name] = true
+	}
+	for _, name := range mapping.Order {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("column_mapping.order: target column %q is not produced by the mapping", name)
+		}
+		if placed[name] {
+			return nil, fmt.Errorf("column_mapping.order: target column %q is listed more than once", name)
+		}
+		add(name)
+	}
+	for _, name := range natural {
+		if !placed[name] {
+			add(name)
+		}
+	}
+
+	if len(m.columns) == 0 {
+		return nil, fmt.Errorf("column_mapping leaves no columns to import")
+	}
+
+	return m, nil
+}
+
+// apply converts a source row into a target row
+func (m *columnMapper) apply(row []interface{}) []interface{} {
+	out := make([]interface{}, len(m.columns))
+	for i, source := range m.sources {
+		switch {
+		case source < 0:
+			out[i] = m.values[i]
+		case source < len(row):
+			out[i] = row[source]
+		default:
+			out[i] = nil
+		}
+	}
+	return out
+}
+
+// isEmpty reports whether the mapping would leave the header unchanged
+func (c *ColumnMapping) isEmpty() bool {
+	return c == nil || (len(c.Rename) == 0 && len(c.Drop) == 0 && len(c.Order) == 0 && len(c.Constants) == 0)
+}
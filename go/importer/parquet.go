@@ -0,0 +1,428 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// parquetReadBatch is the number of rows read from a row group at a time
+const parquetReadBatch = 1024
+
+// ParquetImporter streams the rows of a Parquet file one row group at a
+// time. With a column list only those columns are decoded.
+type ParquetImporter struct {
+	filePath string
+	project  []string // Columns to read ("" = all)
+	file     *os.File
+	pf       *parquet.File
+	schema   *parquet.Schema // Schema rows are read with
+	conv     parquet.Conversion
+	fields   []parquetField
+	nested   bool // Some field needs the row reconstructed
+	group    int  // Next row group to open
+	rows     parquet.Rows
+	size     int64 // Rows in the open row group
+	read     int64 // Rows read from the open row group
+	buf      []parquet.Row
+	pos      int
+	n        int
+	line     int64
+}
+
+// parquetField is a top-level column of the file
+type parquetField struct {
+	name   string
+	node   parquet.Node
+	column int // Leaf column index of a flat field, -1 for groups and lists
+}
+
+// NewParquetImporter creates a new Parquet importer. columns selects and
+// orders the top-level columns to read; empty reads all of them.
+func NewParquetImporter(filePath string, columns []string) *ParquetImporter {
+	return &ParquetImporter{
+		filePath: filePath,
+		project:  columns,
+	}
+}
+
+// Open reads the file footer and returns the column names
+func (p *ParquetImporter) Open() ([]string, error) {
+	file, err := os.Open(p.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	p.file = file
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid Parquet file: %w", err)
+	}
+	p.pf = pf
+
+	if err := p.projectColumns(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	columns := make([]string, len(p.fields))
+	for i, field := range p.fields {
+		columns[i] = field.name
+	}
+	fmt.Fprintf(os.Stderr, "[INFO] Parquet file has %d rows in %d row groups\n", pf.NumRows(), len(pf.RowGroups()))
+	return columns, nil
+}
+
+// projectColumns picks the fields to read. A projection gets a schema of its
+// own, so the column chunks of other fields are never decoded.
+func (p *ParquetImporter) projectColumns() error {
+	fileSchema := p.pf.Schema()
+	byName := make(map[string]parquet.Field)
+	var names []string
+	for _, field := range fileSchema.Fields() {
+		byName[field.Name()] = field
+		names = append(names, field.Name())
+	}
+
+	p.schema = fileSchema
+	if len(p.project) > 0 {
+		group := make(parquet.Group, len(p.project))
+		for _, name := range p.project {
+			field, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("column %q not found in Parquet file (columns: %s)", name, strings.Join(names, ", "))
+			}
+			group[name] = field
+		}
+		names = p.project
+
+		p.schema = parquet.NewSchema(fileSchema.Name(), group)
+		conv, err := parquet.Convert(p.schema, fileSchema)
+		if err != nil {
+			return fmt.Errorf("failed to project Parquet columns: %w", err)
+		}
+		p.conv = conv
+	}
+
+	p.fields = make([]parquetField, len(names))
+	for i, name := range names {
+		field := parquetField{name: name, node: byName[name], column: -1}
+		if field.node.Leaf() && !field.node.Repeated() {
+			leaf, _ := p.schema.Lookup(name)
+			field.column = leaf.ColumnIndex
+		} else {
+			p.nested = true
+		}
+		p.fields[i] = field
+	}
+	return nil
+}
+
+// openGroup starts reading the next row group
+func (p *ParquetImporter) openGroup() {
+	if p.rows != nil {
+		p.rows.Close()
+	}
+	rowGroup := p.pf.RowGroups()[p.group]
+	if p.conv != nil {
+		rowGroup = parquet.ConvertRowGroup(rowGroup, p.conv)
+	}
+	p.group++
+	p.rows = rowGroup.Rows()
+	p.size = rowGroup.NumRows()
+	p.read = 0
+}
+
+// fill reads the next batch of rows, moving on to the next row group when
+// the current one is done
+func (p *ParquetImporter) fill() error {
+	for p.rows == nil || p.read >= p.size {
+		if p.group >= len(p.pf.RowGroups()) {
+			return fmt.Errorf("EOF")
+		}
+		p.openGroup()
+	}
+
+	if p.buf == nil {
+		p.buf = make([]parquet.Row, parquetReadBatch)
+	}
+	n, err := p.rows.ReadRows(p.buf)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read Parquet rows: %w", err)
+	}
+	if n == 0 {
+		// The row group ended early
+		p.read = p.size
+		return p.fill()
+	}
+	p.read += int64(n)
+	p.pos, p.n = 0, n
+	return nil
+}
+
+// NextRow returns the next row
+func (p *ParquetImporter) NextRow() ([]interface{}, error) {
+	if p.pos >= p.n {
+		if err := p.fill(); err != nil {
+			return nil, err
+		}
+	}
+	raw := p.buf[p.pos]
+	p.pos++
+	p.line++
+
+	// Values of flat fields, by leaf column
+	leaves := make(map[int]parquet.Value, len(p.fields))
+	raw.Range(func(column int, values []parquet.Value) bool {
+		if len(values) > 0 {
+			leaves[column] = values[0]
+		}
+		return true
+	})
+
+	var record map[string]interface{}
+	if p.nested {
+		record = make(map[string]interface{})
+		if err := p.schema.Reconstruct(&record, raw); err != nil {
+			return nil, &RowError{Line: p.line, Err: fmt.Errorf("failed to decode row: %w", err)}
+		}
+	}
+
+	row := make([]interface{}, len(p.fields))
+	for i, field := range p.fields {
+		if field.column < 0 {
+			val, err := nestedValue(record[field.name])
+			if err != nil {
+				return nil, &RowError{Line: p.line, Err: fmt.Errorf("column %s: %w", field.name, err)}
+			}
+			row[i] = val
+			continue
+		}
+
+		val, err := parquetValue(field.node, leaves[field.column])
+		if err != nil {
+			return nil, &RowError{Line: p.line, Err: fmt.Errorf("column %s: %w", field.name, err)}
+		}
+		row[i] = val
+	}
+
+	return row, nil
+}
+
+// Skip moves past n rows, skipping whole row groups without reading them
+func (p *ParquetImporter) Skip(n int64) (int64, error) {
+	var skipped int64
+	for skipped < n {
+		switch {
+		case p.pos < p.n:
+			k := int64(p.n - p.pos)
+			if k > n-skipped {
+				k = n - skipped
+			}
+			p.pos += int(k)
+			skipped += k
+
+		case p.rows != nil && p.read < p.size:
+			k := p.size - p.read
+			if k > n-skipped {
+				k = n - skipped
+			}
+			p.read += k
+			if err := p.rows.SeekToRow(p.read); err != nil {
+				return skipped, fmt.Errorf("failed to seek Parquet rows: %w", err)
+			}
+			skipped += k
+
+		case p.group < len(p.pf.RowGroups()):
+			if size := p.pf.RowGroups()[p.group].NumRows(); size <= n-skipped {
+				p.group++
+				skipped += size
+				continue
+			}
+			p.openGroup()
+
+		default:
+			p.line += skipped
+			return skipped, nil
+		}
+	}
+	p.line += skipped
+	return skipped, nil
+}
+
+// Line returns the row number of the last row returned by NextRow
+func (p *ParquetImporter) Line() int64 {
+	return p.line
+}
+
+// Close closes the Parquet file
+func (p *ParquetImporter) Close() error {
+	if p.rows != nil {
+		p.rows.Close()
+	}
+	if p.file != nil {
+		return p.file.Close()
+	}
+	return nil
+}
+
+// parquetValue converts a leaf value to a Go value using the column's
+// logical type: dates and timestamps become time.Time, decimals exact
+// decimal strings, strings and enums string, other binary []byte.
+func parquetValue(node parquet.Node, v parquet.Value) (interface{}, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	logical := node.Type().LogicalType()
+	if logical == nil {
+		logical = &format.LogicalType{}
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean(), nil
+
+	case parquet.Int32:
+		n := v.Int32()
+		switch {
+		case logical.Date != nil:
+			return time.Unix(int64(n)*86400, 0).UTC(), nil
+		case logical.Time != nil:
+			return timeOfDay(time.Duration(n) * time.Millisecond), nil
+		case logical.Decimal != nil:
+			return formatDecimal(big.NewInt(int64(n)), logical.Decimal.Scale), nil
+		case logical.Integer != nil && !logical.Integer.IsSigned:
+			return int64(uint32(n)), nil
+		}
+		return int64(n), nil
+
+	case parquet.Int64:
+		n := v.Int64()
+		switch {
+		case logical.Timestamp != nil:
+			return timestampOf(n, logical.Timestamp.Unit), nil
+		case logical.Time != nil:
+			return timeOfDay(durationOf(n, logical.Time.Unit)), nil
+		case logical.Decimal != nil:
+			return formatDecimal(big.NewInt(n), logical.Decimal.Scale), nil
+		case logical.Integer != nil && !logical.Integer.IsSigned && n < 0:
+			return strconv.FormatUint(uint64(n), 10), nil
+		}
+		return n, nil
+
+	case parquet.Int96:
+		// Legacy timestamp: nanoseconds in the day, then the Julian day
+		i96 := v.Int96()
+		nanos := int64(uint64(i96[1])<<32 | uint64(i96[0]))
+		days := int64(i96[2]) - 2440588 // Julian day of 1970-01-01
+		return time.Unix(days*86400, nanos).UTC(), nil
+
+	case parquet.Float:
+		// Through the shortest float32 text, so 0.1 stays 0.1
+		return strconv.ParseFloat(strconv.FormatFloat(float64(v.Float()), 'g', -1, 32), 64)
+
+	case parquet.Double:
+		return v.Double(), nil
+
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		data := v.ByteArray()
+		switch {
+		case logical.UTF8 != nil, logical.Enum != nil, logical.Json != nil:
+			return string(data), nil
+		case logical.Decimal != nil:
+			return formatDecimal(twosComplement(data), logical.Decimal.Scale), nil
+		case logical.UUID != nil && len(data) == 16:
+			return fmt.Sprintf("%x-%x-%x-%x-%x", data[0:4], data[4:6], data[6:8], data[8:10], data[10:16]), nil
+		}
+		if ct := node.Type().ConvertedType(); ct != nil && *ct == deprecated.UTF8 {
+			return string(data), nil
+		}
+		return append([]byte(nil), data...), nil
+	}
+
+	return nil, fmt.Errorf("unsupported Parquet type %s", node.Type())
+}
+
+// timestampOf converts a count of a Parquet time unit since the epoch to a
+// time. A Duration only spans 292 years, so the count is not turned into one.
+func timestampOf(n int64, unit format.TimeUnit) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(n).UTC()
+	case unit.Micros != nil:
+		return time.UnixMicro(n).UTC()
+	default:
+		return time.Unix(0, n).UTC()
+	}
+}
+
+// durationOf converts a count of a Parquet time unit to a duration. Times of
+// day are well within its range.
+func durationOf(n int64, unit format.TimeUnit) time.Duration {
+	switch {
+	case unit.Millis != nil:
+		return time.Duration(n) * time.Millisecond
+	case unit.Micros != nil:
+		return time.Duration(n) * time.Microsecond
+	default:
+		return time.Duration(n)
+	}
+}
+
+// timeOfDay formats a duration since midnight as a time of day
+func timeOfDay(d time.Duration) string {
+	return time.Time{}.Add(d).Format("15:04:05.999999999")
+}
+
+// twosComplement decodes a big-endian two's complement integer
+func twosComplement(data []byte) *big.Int {
+	n := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	return n
+}
+
+// formatDecimal writes an unscaled integer with scale digits after the point
+func formatDecimal(unscaled *big.Int, scale int32) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		if pad := int(scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(scale)] + "." + digits[len(digits)-int(scale):]
+	}
+	if unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// nestedValue returns a group or list field as JSON text, the way nested
+// JSONL values are kept
+func nestedValue(val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// minRowsForErrorRatio delays max_error_ratio checks until enough rows
+	// have been read for the ratio to mean something
+	minRowsForErrorRatio = 1000
+
+	// maxRejectWarnings caps the rejected rows echoed to stderr
+	maxRejectWarnings = 10
+)
+
+// rejectRecord is one line of the reject file
+type rejectRecord struct {
+	Line   int64         `json:"line"`
+	Error  string        `json:"error"`
+	Values []interface{} `json:"values,omitempty"`
+	Raw    string        `json:"raw,omitempty"`
+}
+
+// rejectLog writes rejected rows to the reject file and enforces the
+// max_errors / max_error_ratio budget. It is safe for concurrent use.
+type rejectLog struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	writer    *bufio.Writer
+	encoder   *json.Encoder
+	maxErrors int64
+	maxRatio  float64
+	rejected  int64
+}
+
+// newRejectLog creates the reject file (if a path is given) and the budget.
+// With neither max_errors nor max_error_ratio set, the first rejected row
+// fails the import. appendFile keeps rows rejected by an earlier run.
+func newRejectLog(path string, maxErrors int64, maxRatio float64, appendFile bool) (*rejectLog, error) {
+	r := &rejectLog{path: path, maxErrors: maxErrors, maxRatio: maxRatio}
+	if path == "" {
+		return r, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendFile {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reject file: %w", err)
+	}
+	r.file = file
+	r.writer = bufio.NewWriter(file)
+	r.encoder = json.NewEncoder(r.writer)
+	r.encoder.SetEscapeHTML(false)
+	return r, nil
+}
+
+// reject records a rejected row and returns an error once the budget is
+// used up. read is the number of rows read so far, for the ratio check.
+func (r *rejectLog) reject(line int64, values []interface{}, raw string, cause error, read int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rejected++
+
+	if r.encoder != nil {
+		rec := rejectRecord{Line: line, Error: cause.Error(), Values: values, Raw: raw}
+		if err := r.encoder.Encode(rec); err != nil {
+			// Values that cannot be encoded are dropped, the error is kept
+			rec.Values = nil
+			if err := r.encoder.Encode(rec); err != nil {
+				return fmt.Errorf("failed to write reject file: %w", err)
+			}
+		}
+	}
+
+	if r.maxErrors == 0 && r.maxRatio == 0 {
+		return fmt.Errorf("line %d: %w", line, cause)
+	}
+
+	if r.rejected <= maxRejectWarnings {
+		fmt.Fprintf(os.Stderr, "[WARN] Rejected line %d: %v\n", line, cause)
+		if r.rejected == maxRejectWarnings {
+			fmt.Fprintf(os.Stderr, "[WARN] Further rejected rows are not logged here\n")
+		}
+	}
+
+	if r.maxErrors > 0 && r.rejected > r.maxErrors {
+		return fmt.Errorf("too many rejected rows: %d exceeds max_errors %d (last: line %d: %v)",
+			r.rejected, r.maxErrors, line, cause)
+	}
+	if read >= minRowsForErrorRatio {
+		return r.checkRatioLocked(read)
+	}
+	return nil
+}
+
+// checkRatio enforces max_error_ratio against the final row count
+func (r *rejectLog) checkRatio(read int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.checkRatioLocked(read)
+}
+
+func (r *rejectLog) checkRatioLocked(read int64) error {
+	if r.maxRatio == 0 || read == 0 {
+		return nil
+	}
+	ratio := float64(r.rejected) / float64(read)
+	if ratio > r.maxRatio {
+		return fmt.Errorf("too many rejected rows: %d of %d (%.2f%%) exceeds max_error_ratio %.2f%%",
+			r.rejected, read, ratio*100, r.maxRatio*100)
+	}
+	return nil
+}
+
+// count returns the number of rejected rows
+func (r *rejectLog) count() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rejected
+}
+
+// Close flushes and closes the reject file
+func (r *rejectLog) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return fmt.Errorf("failed to flush reject file: %w", err)
+	}
+	return r.file.Close()
+}
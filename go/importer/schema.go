@@ -0,0 +1,258 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datamill/data-engine/go/db"
+)
+
+// DefaultSchemaSample is the number of rows sampled to infer column types
+const DefaultSchemaSample = 1000
+
+// inferLayouts are the strict layouts used to recognise dates and
+// timestamps while sampling; parsing at load time is more lenient
+var (
+	inferDateLayouts      = []string{"2006-01-02", "2006/01/02"}
+	inferTimestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+	}
+)
+
+// columnStats accumulates what has been seen in one column of the sample
+type columnStats struct {
+	kind      string // Widest kind seen so far, "" until a value is seen
+	intDigits int
+	scale     int
+}
+
+// inferSchema infers column definitions from sampled rows. Every inferred
+// column is nullable since the sample may not contain the NULLs to come.
+func inferSchema(columns []string, rows [][]interface{}) []db.ColumnInfo {
+	stats := make([]columnStats, len(columns))
+	for _, row := range rows {
+		for i := range columns {
+			if i < len(row) {
+				stats[i].observe(row[i])
+			}
+		}
+	}
+
+	result := make([]db.ColumnInfo, len(columns))
+	for i, name := range columns {
+		kind := stats[i].kind
+		if kind == "" {
+			kind = db.KindText
+		}
+		result[i] = db.ColumnInfo{
+			Name:      name,
+			Kind:      kind,
+			Nullable:  true,
+			Precision: stats[i].intDigits + stats[i].scale,
+			Scale:     stats[i].scale,
+		}
+	}
+	return result
+}
+
+// observe widens the column kind to accommodate val
+func (s *columnStats) observe(val interface{}) {
+	kind := s.classify(val)
+	if kind == "" {
+		return
+	}
+	s.kind = widenKind(s.kind, kind)
+}
+
+// classify returns the narrowest kind that can hold val, or "" for NULLs
+func (s *columnStats) classify(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case bool:
+		return db.KindBoolean
+	case int64, int:
+		return db.KindInteger
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return db.KindInteger
+		}
+		s.observeNumeric(strconv.FormatFloat(v, 'f', -1, 64))
+		return db.KindNumeric
+	case time.Time:
+		return db.KindTimestamp
+	case map[string]interface{}, []interface{}:
+		return db.KindJSON
+	case string:
+		return s.classifyString(v)
+	default:
+		return db.KindText
+	}
+}
+
+// classifyString infers the kind of a textual value
+func (s *columnStats) classifyString(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return ""
+	}
+
+	// Leading zeros (zip codes, account numbers) must survive as text
+	if digits := strings.TrimLeft(v, "+-"); len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return db.KindText
+	}
+
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		s.observeNumeric(v)
+		return db.KindInteger
+	}
+	if numericPattern.MatchString(v) {
+		s.observeNumeric(v)
+		return db.KindNumeric
+	}
+
+	switch strings.ToLower(v) {
+	case "true", "false":
+		return db.KindBoolean
+	}
+
+	for _, layout := range inferDateLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return db.KindDate
+		}
+	}
+	for _, layout := range inferTimestampLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return db.KindTimestamp
+		}
+	}
+
+	if (v[0] == '{' || v[0] == '[') && json.Valid([]byte(v)) {
+		return db.KindJSON
+	}
+
+	return db.KindText
+}
+
+// observeNumeric tracks the digits needed to hold a decimal literal
+func (s *columnStats) observeNumeric(v string) {
+	v = strings.TrimLeft(v, "+-")
+	if strings.ContainsAny(v, "eE") {
+		// Exponent notation: fall back to a generous fixed size
+		s.intDigits, s.scale = maxInt(s.intDigits, 28), maxInt(s.scale, 10)
+		return
+	}
+
+	intPart, fracPart := v, ""
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		intPart, fracPart = v[:i], v[i+1:]
+	}
+	s.intDigits = maxInt(s.intDigits, len(strings.TrimLeft(intPart, "0")))
+	s.scale = maxInt(s.scale, len(fracPart))
+}
+
+// widenKind returns the narrowest kind able to hold values of both kinds
+func widenKind(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case (a == db.KindInteger && b == db.KindNumeric) || (a == db.KindNumeric && b == db.KindInteger):
+		return db.KindNumeric
+	case (a == db.KindDate && b == db.KindTimestamp) || (a == db.KindTimestamp && b == db.KindDate):
+		return db.KindTimestamp
+	default:
+		return db.KindText
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// sampledRow is a row read ahead of the import, with its line and any
+// RowError so it can be replayed exactly as it was read
+type sampledRow struct {
+	values []interface{}
+	line   int64
+	err    error
+}
+
+// replayImporter returns buffered rows before continuing with the wrapped
+// importer, so rows read for sampling are still imported
+type replayImporter struct {
+	Importer
+	buffered []sampledRow
+	line     int64
+}
+
+// sampleRows reads up to n rows from an opened importer and returns the
+// parseable ones together with an importer that replays all of them first
+func sampleRows(importer Importer, n int) ([][]interface{}, Importer, error) {
+	replay := &replayImporter{Importer: importer}
+	rows := make([][]interface{}, 0, n)
+	for len(replay.buffered) < n {
+		row, err := importer.NextRow()
+		if err != nil {
+			if err.Error() == "EOF" {
+				break
+			}
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) {
+				return nil, nil, fmt.Errorf("failed to read sample row: %w", err)
+			}
+			replay.buffered = append(replay.buffered, sampledRow{err: err})
+			continue
+		}
+		replay.buffered = append(replay.buffered, sampledRow{values: row, line: importer.Line()})
+		rows = append(rows, row)
+	}
+
+	return rows, replay, nil
+}
+
+// NextRow returns buffered rows first, then rows from the wrapped importer
+func (r *replayImporter) NextRow() ([]interface{}, error) {
+	if len(r.buffered) > 0 {
+		next := r.buffered[0]
+		r.buffered = r.buffered[1:]
+		r.line = next.line
+		return next.values, next.err
+	}
+	r.line = -1
+	return r.Importer.NextRow()
+}
+
+// Skip drops buffered rows first, then skips in the wrapped importer
+func (r *replayImporter) Skip(n int64) (int64, error) {
+	var skipped int64
+	for skipped < n && len(r.buffered) > 0 {
+		r.line = r.buffered[0].line
+		r.buffered = r.buffered[1:]
+		skipped++
+	}
+	if skipped == n {
+		return skipped, nil
+	}
+	r.line = -1
+	more, err := r.Importer.Skip(n - skipped)
+	return skipped + more, err
+}
+
+// Line returns the source line of the last row returned by NextRow
+func (r *replayImporter) Line() int64 {
+	if r.line >= 0 {
+		return r.line
+	}
+	return r.Importer.Line()
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/datamill/data-engine/go/db"
+)
+
+// SniffSize is how much of a text file is sampled to sniff its layout
+const SniffSize = 64 * 1024
+
+// sniffRecords is the most records compared when sniffing a delimiter
+const sniffRecords = 100
+
+// sniffDelimiters are the delimiters tried, in order of preference on a tie
+var sniffDelimiters = []rune{',', '\t', ';', '|'}
+
+// SniffFormat tells JSONL and JSON apart from delimited text by the first
+// line of a sample: a complete JSON object means jsonl, an opening bracket
+// json, anything else csv. complete is false when the sample stops short of
+// the end of the file; an object still open at the end of the sample is
+// then taken for jsonl, as its line is longer than the sample.
+func SniffFormat(sample []byte, complete bool) string {
+	for len(sample) > 0 {
+		line := sample
+		cut := !complete
+		if end := bytes.IndexByte(sample, '\n'); end >= 0 {
+			line, sample, cut = sample[:end], sample[end+1:], false
+		} else {
+			sample = nil
+		}
+
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0:
+			continue
+		case line[0] == '{' && (cut || json.Valid(line)):
+			return "jsonl"
+		case line[0] == '[':
+			return "json"
+		}
+		break
+	}
+	return "csv"
+}
+
+// CSVSniff is the dialect the first rows of a delimited file suggest
+type CSVSniff struct {
+	Delimiter rune
+	Header    bool // The first row looks like column names
+}
+
+// SniffCSV guesses the delimiter and whether there is a header from a
+// sample of a delimited file. The delimiter is the candidate that splits
+// the most rows into the same number of fields, at least two; a delimiter
+// already set in dialect is kept. complete is false when the sample stops
+// short of the end of the file, so its last line may be cut off.
+func SniffCSV(sample []byte, complete bool, dialect CSVDialect) CSVSniff {
+	if !complete {
+		if end := bytes.LastIndexByte(sample, '\n'); end >= 0 {
+			sample = sample[:end+1]
+		}
+	}
+	if dialect.SkipLines > 0 {
+		skipped, _ := skipLines(bufio.NewReader(bytes.NewReader(sample)), dialect.SkipLines)
+		sample = sample[skipped:]
+	}
+
+	candidates := sniffDelimiters
+	if dialect.Delimiter != 0 {
+		candidates = []rune{dialect.Delimiter}
+	}
+
+	best := CSVSniff{Delimiter: candidates[0], Header: true}
+	var bestRecords [][]string
+	bestScore, bestFields := 0.0, 0
+	for _, delimiter := range candidates {
+		d := dialect
+		d.Delimiter = delimiter
+		records := sniffRead(sample, d)
+		fields, score := fieldConsistency(records)
+		if fields < 2 && len(candidates) > 1 {
+			continue
+		}
+		if score > bestScore || (score == bestScore && fields > bestFields) {
+			best.Delimiter = delimiter
+			bestRecords = records
+			bestScore, bestFields = score, fields
+		}
+	}
+
+	best.Header = guessHeader(bestRecords)
+	return best
+}
+
+// sniffRead reads the first records of a sample
+func sniffRead(sample []byte, dialect CSVDialect) [][]string {
+	reader := newRecordReader(bytes.NewReader(sample), dialect)
+	var records [][]string
+	for len(records) < sniffRecords {
+		record, err := reader.Read()
+		if record == nil && err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// fieldConsistency returns the most common field count of the records and
+// the fraction of records that have it
+func fieldConsistency(records [][]string) (int, float64) {
+	if len(records) == 0 {
+		return 0, 0
+	}
+	counts := make(map[int]int)
+	fields, most := 0, 0
+	for _, record := range records {
+		counts[len(record)]++
+		if n := counts[len(record)]; n > most || (n == most && len(record) > fields) {
+			fields, most = len(record), n
+		}
+	}
+	return fields, float64(most) / float64(len(records))
+}
+
+// guessHeader reports whether the first record looks like column names.
+// Without rows to compare it with, or with nothing but text, a header is
+// assumed; a first row holding empty or repeated names, numbers, booleans
+// or dates is taken as data.
+func guessHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return true
+	}
+	seen := make(map[string]bool, len(records[0]))
+	for _, field := range records[0] {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] || looksLikeValue(field) {
+			return false
+		}
+		seen[field] = true
+	}
+	return true
+}
+
+// looksLikeValue reports whether a field reads as something other than
+// text. Decimal commas count as numbers.
+func looksLikeValue(field string) bool {
+	var stats columnStats
+	kind := stats.classifyString(field)
+	if kind == db.KindText && strings.Count(field, ",") == 1 {
+		kind = stats.classifyString(strings.Replace(field, ",", ".", 1))
+	}
+	return kind != db.KindText && kind != db.KindJSON
+}
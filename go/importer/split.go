@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// minParseRange is the smallest byte range worth handing to its own parser.
+// Tests lower it to split small inputs.
+var minParseRange int64 = 4 * 1024 * 1024
+
+// rowReader is the part of an Importer that produces rows
+type rowReader interface {
+	NextRow() ([]interface{}, error)
+	Line() int64
+}
+
+// splitter is implemented by importers whose input can be divided into byte
+// ranges holding whole records and parsed by several goroutines
+type splitter interface {
+	split(n int) ([]rowReader, error)
+}
+
+// byteRange is a section of the input that starts on a record boundary
+type byteRange struct {
+	start int64
+	end   int64
+	lines int64 // Newlines before start, to keep line numbers absolute
+}
+
+// Record scanner states, following how encoding/csv reads a record
+const (
+	stateFieldStart = iota // Start of a field or record
+	stateUnquoted          // Inside an unquoted field
+	stateQuoted            // Inside a quoted field
+	stateQuote             // Quote seen inside a quoted field
+	numStates
+
+	recordEnd = 0x80 // Set on a transition that ends a record
+)
+
+// recordScanner finds record boundaries without knowing the parser state at
+// the point where a scan starts. Each chunk is scanned once from every state
+// at the same time; the true state is only resolved afterwards, chunk by
+// chunk, so a newline inside a quoted multiline field is never mistaken for
+// the end of a record.
+type recordScanner struct {
+	next [numStates][256]uint8
+}
+
+// newRecordScanner builds the transition table for a CSV dialect. quotes
+// false treats every newline as a record end, as in JSONL.
+func newRecordScanner(delimiter byte, quotes, trimSpace bool) *recordScanner {
+	s := &recordScanner{}
+	for b := 0; b < 256; b++ {
+		s.next[stateFieldStart][b] = stateUnquoted
+		s.next[stateUnquoted][b] = stateUnquoted
+		s.next[stateQuoted][b] = stateQuoted
+		s.next[stateQuote][b] = stateQuoted // LazyQuotes keeps a stray quote
+	}
+
+	for _, state := range []int{stateFieldStart, stateUnquoted, stateQuote} {
+		s.next[state][delimiter] = stateFieldStart
+		s.next[state]['\n'] = stateFieldStart | recordEnd
+		s.next[state]['\r'] = uint8(state)
+	}
+
+	if trimSpace {
+		for _, b := range []byte{' ', '\t'} {
+			if b != delimiter {
+				s.next[stateFieldStart][b] = stateFieldStart
+			}
+		}
+	}
+
+	if quotes {
+		s.next[stateFieldStart]['"'] = stateQuoted
+		s.next[stateQuoted]['"'] = stateQuote
+		s.next[stateQuote]['"'] = stateQuoted // Escaped quote
+	}
+
+	return s
+}
+
+// chunkScan is what a chunk looks like from each possible starting state
+type chunkScan struct {
+	newlines int64
+	end      [numStates]uint8
+	boundary [numStates]int64 // Offset just past the first record end, -1 if none
+	lines    [numStates]int64 // Newlines up to and including boundary
+}
+
+// scanChunk scans file[start:end) from every state at once
+func (s *recordScanner) scanChunk(file *os.File, start, end int64) (chunkScan, error) {
+	var scan chunkScan
+	var states [numStates]uint8
+	for i := range states {
+		states[i] = uint8(i)
+		scan.boundary[i] = -1
+	}
+
+	size := int64(1024 * 1024)
+	if end-start < size {
+		size = end - start
+	}
+	buf := make([]byte, size)
+	section := io.NewSectionReader(file, start, end-start)
+	offset := start
+	for {
+		n, err := section.Read(buf)
+		for _, b := range buf[:n] {
+			if b == '\n' {
+				scan.newlines++
+			}
+			for i := range states {
+				next := s.next[states[i]][b]
+				if next&recordEnd != 0 && scan.boundary[i] < 0 {
+					scan.boundary[i] = offset + 1
+					scan.lines[i] = scan.newlines
+				}
+				states[i] = next &^ recordEnd
+			}
+			offset++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return scan, err
+		}
+	}
+
+	scan.end = states
+	return scan, nil
+}
+
+// splitRanges divides file[start:end) into about n ranges that each begin
+// on a record boundary. lines is the number of newlines before start.
+func (s *recordScanner) splitRanges(file *os.File, start, end, lines int64, n int) ([]byteRange, error) {
+	if max := int((end - start) / minParseRange); n > max {
+		n = max
+	}
+	if n <= 1 {
+		return []byteRange{{start: start, end: end, lines: lines}}, nil
+	}
+
+	// Scan the chunks in parallel
+	size := (end - start) / int64(n)
+	scans := make([]chunkScan, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		chunkStart := start + int64(i)*size
+		chunkEnd := chunkStart + size
+		if i == n-1 {
+			chunkEnd = end
+		}
+		wg.Add(1)
+		go func(i int, chunkStart, chunkEnd int64) {
+			defer wg.Done()
+			scans[i], errs[i] = s.scanChunk(file, chunkStart, chunkEnd)
+		}(i, chunkStart, chunkEnd)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to split input: %w", err)
+		}
+	}
+
+	// Resolve the real state at each chunk start, beginning with the first
+	// record, and cut at the first record boundary after it
+	ranges := []byteRange{{start: start, lines: lines}}
+	state := uint8(stateFieldStart)
+	for i, scan := range scans {
+		if i > 0 && scan.boundary[state] >= 0 && scan.boundary[state] < end {
+			ranges[len(ranges)-1].end = scan.boundary[state]
+			ranges = append(ranges, byteRange{start: scan.boundary[state], lines: lines + scan.lines[state]})
+		}
+		lines += scan.newlines
+		state = scan.end[state]
+	}
+	ranges[len(ranges)-1].end = end
+
+	return ranges, nil
+}
+
+// countNewlines counts the newlines in file[0:end)
+func countNewlines(file *os.File, end int64) (int64, error) {
+	buf := make([]byte, 64*1024)
+	section := io.NewSectionReader(file, 0, end)
+	var count int64
+	for {
+		n, err := section.Read(buf)
+		count += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
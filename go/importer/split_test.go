@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readRows reads every row of r with the line it started on
+func readRows(t *testing.T, r rowReader) ([][]interface{}, []int64) {
+	t.Helper()
+	var rows [][]interface{}
+	var lines []int64
+	for {
+		row, err := r.NextRow()
+		if err != nil && err.Error() == "EOF" {
+			return rows, lines
+		}
+		if err != nil {
+			t.Fatalf("NextRow: %v", err)
+		}
+		rows = append(rows, row)
+		lines = append(lines, r.Line())
+	}
+}
+
+// splitTest checks that every split of a file into 2 to maxWorkers ranges
+// yields the rows and line numbers of one sequential read
+func splitTest(t *testing.T, open func() (Importer, error), maxWorkers int) {
+	t.Helper()
+	saved := minParseRange
+	minParseRange = 1
+	defer func() { minParseRange = saved }()
+
+	sequential, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows, wantLines := readRows(t, sequential)
+	sequential.Close()
+	if len(wantRows) == 0 {
+		t.Fatal("no rows read")
+	}
+
+	for n := 2; n <= maxWorkers; n++ {
+		imp, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers, err := imp.(splitter).split(n)
+		if err != nil {
+			t.Fatalf("split(%d): %v", n, err)
+		}
+		var gotRows [][]interface{}
+		var gotLines []int64
+		for _, r := range readers {
+			rows, lines := readRows(t, r)
+			gotRows = append(gotRows, rows...)
+			gotLines = append(gotLines, lines...)
+		}
+		imp.Close()
+
+		if !reflect.DeepEqual(gotRows, wantRows) {
+			t.Errorf("split(%d) into %d ranges: rows %q, want %q", n, len(readers), gotRows, wantRows)
+		}
+		if !reflect.DeepEqual(gotLines, wantLines) {
+			t.Errorf("split(%d) into %d ranges: lines %v, want %v", n, len(readers), gotLines, wantLines)
+		}
+	}
+}
+
+func TestCSVSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		workers int // Most parse workers tried
+	}{
+		{
+			name:    "plain",
+			content: "id,name\n1,a\n2,b\n3,c\n4,d\n5,e\n",
+		},
+		{
+			name:    "quoted newlines",
+			content: "id,note\n1,\"first\nsecond\"\n2,plain\n3,\"a\n\nb\nc\"\n4,\"x,\ny\"\n5,z\n",
+		},
+		{
+			name:    "escaped quotes",
+			content: "id,note\n1,\"say \"\"hi\"\"\"\n2,\"\"\"\"\n3,\"a \"\"\nb\"\"\"\n4,\"\"\n5,\"\"\"x\"\"\"\n",
+		},
+		{
+			name:    "CRLF",
+			content: "id,note\r\n1,a\r\n2,\"b\r\nc\"\r\n3,d\r\n4,\"e\"\"\r\n\"\r\n",
+		},
+		{
+			name:    "byte order mark",
+			content: "\xef\xbb\xbfid,name\n1,\"a\nb\"\n2,c\n3,d\n",
+		},
+		{
+			name:    "empty lines",
+			content: "id,name\n\n1,a\n\n\n2,\"b\n\nc\"\n\n3,d\n",
+		},
+		{
+			name:    "more workers than lines",
+			content: "id,name\n1,a\n2,b\n",
+			workers: 64,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "data.csv", tt.content)
+			open := func() (Importer, error) {
+				imp := NewCSVImporter(path, CSVDialect{Delimiter: ',', Quote: '"'}, CompressionNone, EncodingAuto)
+				_, err := imp.Open()
+				return imp, err
+			}
+			workers := tt.workers
+			if workers == 0 {
+				workers = len(tt.content) // Every chunk size down to a byte
+			}
+			splitTest(t, open, workers)
+		})
+	}
+}
+
+func TestJSONLSplit(t *testing.T) {
+	lines := []string{`{"id":1,"s":"a\nb"}`, `{"id":2,"s":"}{"}`, `{"id":3}`, `{"id":4,"s":"\"x\""}`}
+	content := "\xef\xbb\xbf" + strings.Join(lines, "\n") + "\n"
+	path := writeFile(t, t.TempDir(), "data.jsonl", content)
+	open := func() (Importer, error) {
+		imp := NewJSONLImporter(path, CompressionNone, EncodingAuto, JSONLOptions{})
+		_, err := imp.Open()
+		return imp, err
+	}
+	splitTest(t, open, len(content))
+}
+
+func TestCountNewlines(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "data.txt", "a\nb\r\n\nc")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for end, want := range map[int64]int64{0: 0, 1: 0, 2: 1, 5: 2, 6: 3, 7: 3} {
+		if got, err := countNewlines(file, end); err != nil || got != want {
+			t.Errorf("countNewlines(%d) = %d, %v, want %d", end, got, err, want)
+		}
+	}
+}
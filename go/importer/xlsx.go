@@ -3,106 +3,348 @@ package importer
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
 
+// DefaultXLSXMaxSize is the largest workbook loaded by excelize unless
+// configured otherwise (100MB)
+const DefaultXLSXMaxSize = 100 * 1024 * 1024
+
+// How workbooks are read
+const (
+	XLSXReaderAuto     = "auto"     // excelize up to the size limit, streaming above it
+	XLSXReaderExcelize = "excelize" // excelize, refusing workbooks over the size limit
+	XLSXReaderStream   = "stream"   // Worksheet XML streamed from the zip
+)
+
+// How the sheets of a workbook are imported
 const (
-	// MaxXLSXSize is the maximum allowed XLSX file size (100MB)
-	MaxXLSXSize = 100 * 1024 * 1024
+	SheetsSingle   = "single"    // One sheet, chosen by name or index
+	SheetsCombined = "combined"  // Every sheet into one table
+	SheetsPerTable = "per-sheet" // Every sheet into its own table
 )
 
+// XLSXOptions selects the sheets and rows to read from a workbook. Row
+// numbers are sheet rows, starting at 1.
+type XLSXOptions struct {
+	Sheet        string // Sheet name ("" = use SheetIndex)
+	SheetIndex   int    // Sheet position, starting at 1 (0 = first sheet)
+	HeaderRow    int    // Row holding the column names (0 = 1)
+	DataStartRow int    // First row with data (0 = the row after the header)
+	Sheets       string // One of the Sheets* constants ("" = single)
+	SheetColumn  string // With SheetsCombined, column receiving the sheet name
+	Reader       string // One of the XLSXReader* constants ("" = auto)
+	MaxSize      int64  // Size limit for excelize (0 = DefaultXLSXMaxSize)
+	Typed        bool   // Typed cell values instead of text; needs the streaming reader
+}
+
+// workbook is a source of worksheet rows
+type workbook interface {
+	SheetList() []string
+	Rows(sheet string) (sheetReader, error)
+	Close() error
+}
+
+// sheetReader streams the rows of a worksheet. Each Next moves one sheet
+// row, so rows without cells come back empty.
+type sheetReader interface {
+	Next() bool
+	Columns() ([]string, error)
+	Error() error
+	Close() error
+}
+
+// typedSheetReader is a sheetReader that can return cell values with their
+// types instead of as text
+type typedSheetReader interface {
+	sheetReader
+	Values() ([]interface{}, error)
+}
+
+// excelizeBook reads a workbook through excelize, which loads the whole
+// archive and shared string table into memory
+type excelizeBook struct {
+	file *excelize.File
+}
+
+func (b *excelizeBook) SheetList() []string {
+	return b.file.GetSheetList()
+}
+
+func (b *excelizeBook) Rows(sheet string) (sheetReader, error) {
+	rows, err := b.file.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return excelizeRows{rows}, nil
+}
+
+func (b *excelizeBook) Close() error {
+	return b.file.Close()
+}
+
+// excelizeRows adapts excelize.Rows to sheetReader
+type excelizeRows struct {
+	*excelize.Rows
+}
+
+func (r excelizeRows) Columns() ([]string, error) {
+	return r.Rows.Columns()
+}
+
+// openWorkbook opens a workbook with the reader the options ask for
+func openWorkbook(filePath string, opts XLSXOptions) (workbook, error) {
+	// Check file size
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultXLSXMaxSize
+	}
+	reader := opts.Reader
+	switch {
+	case opts.Typed:
+		// Only the streaming reader sees cell types and styles
+		reader = XLSXReaderStream
+	case reader == "" || reader == XLSXReaderAuto:
+		reader = XLSXReaderExcelize
+		if info.Size() > maxSize {
+			fmt.Fprintf(os.Stderr, "[INFO] XLSX file is %d bytes, over xlsx_max_size %d: using the streaming reader\n", info.Size(), maxSize)
+			reader = XLSXReaderStream
+		}
+	}
+
+	if reader == XLSXReaderStream {
+		return openStreamBook(filePath)
+	}
+
+	if info.Size() > maxSize {
+		return nil, fmt.Errorf("XLSX file too large: %d bytes (max %d bytes). Raise xlsx_max_size or use xlsx_reader stream",
+			info.Size(), maxSize)
+	}
+
+	// Open XLSX file
+	file, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open XLSX file: %w", err)
+	}
+	return &excelizeBook{file: file}, nil
+}
+
 // XLSXImporter handles XLSX file imports with streaming
 type XLSXImporter struct {
 	filePath string
-	file     *excelize.File
-	rows     *excelize.Rows
+	opts     XLSXOptions
+	book     workbook
+	rows     sheetReader
+	sheets   []string // Sheets still to read after the current one
+	sheet    string
+	sources  []int // Column index in the current sheet for each column, or sourceSheet or sourceMissing
 	columns  []string
+	line     int64
 }
 
+// Column sources that are not a column of the current sheet
+const (
+	sourceSheet   = -1 // The sheet name column
+	sourceMissing = -2 // Missing in the current sheet, read as NULL
+)
+
 // NewXLSXImporter creates a new XLSX importer
-func NewXLSXImporter(filePath string) *XLSXImporter {
+func NewXLSXImporter(filePath string, opts XLSXOptions) *XLSXImporter {
 	return &XLSXImporter{
 		filePath: filePath,
+		opts:     opts,
 	}
 }
 
 // Open opens the XLSX file and validates size limits
 func (x *XLSXImporter) Open() ([]string, error) {
-	// Check file size
-	info, err := os.Stat(x.filePath)
+	book, err := openWorkbook(x.filePath, x.opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat file: %w", err)
+		return nil, err
 	}
+	x.book = book
 
-	if info.Size() > MaxXLSXSize {
-		return nil, fmt.Errorf("XLSX file too large: %d bytes (max %d bytes). Please convert to CSV for large files", 
-			info.Size(), MaxXLSXSize)
+	sheets, err := selectSheets(book.SheetList(), x.opts)
+	if err != nil {
+		book.Close()
+		return nil, err
 	}
 
-	// Open XLSX file
-	file, err := excelize.OpenFile(x.filePath)
+	// Columns come from the first sheet; later sheets are matched by name
+	header, err := x.openSheet(sheets[0])
 	if err != nil {
-		return nil, fmt.Errorf("failed to open XLSX file: %w", err)
+		x.Close()
+		return nil, err
 	}
-	x.file = file
+	x.sheets = sheets[1:]
 
-	// Get first sheet name
-	sheets := file.GetSheetList()
+	x.columns = header
+	x.sources = make([]int, len(header))
+	for i := range header {
+		x.sources[i] = i
+	}
+	if x.opts.Sheets == SheetsCombined {
+		x.columns = append(x.columns, x.opts.SheetColumn)
+		x.sources = append(x.sources, sourceSheet)
+	}
+
+	return x.columns, nil
+}
+
+// selectSheets returns the sheets to read, in workbook order
+func selectSheets(sheets []string, opts XLSXOptions) ([]string, error) {
 	if len(sheets) == 0 {
-		file.Close()
 		return nil, fmt.Errorf("no sheets found in XLSX file")
 	}
 
-	sheetName := sheets[0]
-	fmt.Fprintf(os.Stderr, "[INFO] Reading sheet: %s\n", sheetName)
+	switch {
+	case opts.Sheets == SheetsCombined:
+		return sheets, nil
+	case opts.Sheet != "":
+		for _, name := range sheets {
+			if name == opts.Sheet {
+				return []string{name}, nil
+			}
+		}
+		return nil, fmt.Errorf("sheet %q not found (sheets: %s)", opts.Sheet, strings.Join(sheets, ", "))
+	case opts.SheetIndex > 0:
+		if opts.SheetIndex > len(sheets) {
+			return nil, fmt.Errorf("sheet index %d out of range, workbook has %d sheets", opts.SheetIndex, len(sheets))
+		}
+		return []string{sheets[opts.SheetIndex-1]}, nil
+	default:
+		return sheets[:1], nil
+	}
+}
+
+// openSheet starts streaming a sheet, reads its header row and moves to
+// the first data row
+func (x *XLSXImporter) openSheet(sheet string) ([]string, error) {
+	if x.rows != nil {
+		x.rows.Close()
+	}
+	fmt.Fprintf(os.Stderr, "[INFO] Reading sheet: %s\n", sheet)
 
 	// Open streaming reader
-	rows, err := file.Rows(sheetName)
+	rows, err := x.book.Rows(sheet)
 	if err != nil {
-		file.Close()
 		return nil, fmt.Errorf("failed to create row iterator: %w", err)
 	}
 	x.rows = rows
+	x.sheet = sheet
+	x.line = 0
+
+	headerRow := x.opts.HeaderRow
+	if headerRow <= 0 {
+		headerRow = 1
+	}
+	dataStartRow := x.opts.DataStartRow
+	if dataStartRow <= 0 {
+		dataStartRow = headerRow + 1
+	}
 
 	// Read header row
-	if !rows.Next() {
-		rows.Close()
-		file.Close()
-		return nil, fmt.Errorf("empty sheet")
+	for x.line < int64(headerRow) {
+		if !rows.Next() {
+			if err := rows.Error(); err != nil {
+				return nil, fmt.Errorf("row iteration error: %w", err)
+			}
+			return nil, fmt.Errorf("sheet %s has no header row %d", sheet, headerRow)
+		}
+		x.line++
 	}
 
 	header, err := rows.Columns()
 	if err != nil {
-		rows.Close()
-		file.Close()
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	x.columns = header
+	// Skip to the row before the data
+	for x.line < int64(dataStartRow-1) && rows.Next() {
+		x.line++
+	}
+	if err := rows.Error(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
 	return header, nil
 }
 
+// nextSheet moves on to the next sheet and matches its header against the
+// columns of the first one
+func (x *XLSXImporter) nextSheet() error {
+	sheet := x.sheets[0]
+	x.sheets = x.sheets[1:]
+
+	header, err := x.openSheet(sheet)
+	if err != nil {
+		return err
+	}
+
+	position := make(map[string]int, len(header))
+	for i, name := range header {
+		position[name] = i
+	}
+	for i, col := range x.columns {
+		if x.sources[i] == sourceSheet {
+			continue
+		}
+		if pos, ok := position[col]; ok {
+			x.sources[i] = pos
+			delete(position, col)
+		} else {
+			x.sources[i] = sourceMissing
+		}
+	}
+	for name := range position {
+		if name != "" {
+			return fmt.Errorf("sheet %s has column %q that the first sheet does not have", sheet, name)
+		}
+	}
+
+	return nil
+}
+
 // NextRow reads the next row from the XLSX file
 func (x *XLSXImporter) NextRow() ([]interface{}, error) {
-	if !x.rows.Next() {
+	for !x.rows.Next() {
 		if err := x.rows.Error(); err != nil {
 			return nil, fmt.Errorf("row iteration error: %w", err)
 		}
-		return nil, fmt.Errorf("EOF")
+		if len(x.sheets) == 0 {
+			return nil, fmt.Errorf("EOF")
+		}
+		if err := x.nextSheet(); err != nil {
+			return nil, err
+		}
 	}
+	x.line++
 
-	cols, err := x.rows.Columns()
+	cols, err := x.cells()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read row: %w", err)
 	}
 
 	// Convert to interface slice and pad if necessary
 	row := make([]interface{}, len(x.columns))
-	for i := 0; i < len(x.columns); i++ {
-		if i < len(cols) {
-			row[i] = cols[i]
-		} else {
+	for i, source := range x.sources {
+		switch {
+		case source == sourceSheet:
+			row[i] = x.sheet
+		case source == sourceMissing:
+			row[i] = nil
+		case source < len(cols):
+			row[i] = cols[source]
+		default:
 			row[i] = nil
 		}
 	}
@@ -110,13 +352,83 @@ func (x *XLSXImporter) NextRow() ([]interface{}, error) {
 	return row, nil
 }
 
+// cells returns the current row, typed or as text
+func (x *XLSXImporter) cells() ([]interface{}, error) {
+	if x.opts.Typed {
+		return x.rows.(typedSheetReader).Values()
+	}
+
+	cols, err := x.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(cols))
+	for i, col := range cols {
+		values[i] = col
+	}
+	return values, nil
+}
+
+// Skip moves past n rows without reading their cells
+func (x *XLSXImporter) Skip(n int64) (int64, error) {
+	var skipped int64
+	for skipped < n {
+		if x.rows.Next() {
+			x.line++
+			skipped++
+			continue
+		}
+		if err := x.rows.Error(); err != nil {
+			return skipped, fmt.Errorf("row iteration error: %w", err)
+		}
+		if len(x.sheets) == 0 {
+			break
+		}
+		if err := x.nextSheet(); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// Line returns the sheet row number of the last row returned by NextRow
+func (x *XLSXImporter) Line() int64 {
+	return x.line
+}
+
 // Close closes the XLSX file
 func (x *XLSXImporter) Close() error {
 	if x.rows != nil {
 		x.rows.Close()
 	}
-	if x.file != nil {
-		return x.file.Close()
+	if x.book != nil {
+		return x.book.Close()
 	}
 	return nil
 }
+
+// XLSXSheetList returns the sheet names of a workbook in order
+func XLSXSheetList(filePath string, opts XLSXOptions) ([]string, error) {
+	book, err := openWorkbook(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer book.Close()
+
+	return book.SheetList(), nil
+}
+
+// unsafeTableChars matches runs of characters not allowed in a generated
+// table name
+var unsafeTableChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// sheetTableName expands a table name template for a sheet. {table} is the
+// configured table, {sheet} the sheet name reduced to [a-z0-9_] and
+// {index} the sheet position starting at 1.
+func sheetTableName(template, table, sheet string, index int) string {
+	name := strings.Trim(unsafeTableChars.ReplaceAllString(strings.ToLower(sheet), "_"), "_")
+	if name == "" {
+		name = "sheet" + strconv.Itoa(index)
+	}
+	return strings.NewReplacer("{table}", table, "{sheet}", name, "{index}", strconv.Itoa(index)).Replace(template)
+}
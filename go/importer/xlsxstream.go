@@ -0,0 +1,799 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"container/list"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sharedStringCache is the number of shared strings kept in memory by the
+// streaming reader; the rest are read back from a temporary file
+const sharedStringCache = 65536
+
+// streamBook reads a workbook straight from the zip archive. Worksheet XML
+// is decoded as it is read and shared strings are spilled to a temporary
+// file, so memory stays flat whatever the size of the workbook.
+type streamBook struct {
+	zip      *zip.ReadCloser
+	files    map[string]*zip.File // Archive members by lowercased name
+	sheets   []string
+	targets  map[string]string // Worksheet part by sheet name
+	date1904 bool
+	dates    []bool         // Cell formats that display a date or time
+	numFmts  []int          // Number format of each cell format
+	custom   map[int]string // Custom number format codes by id
+	format   *numberFormatter
+	strings  *sharedStrings
+}
+
+// openStreamBook opens a workbook and indexes its shared strings
+func openStreamBook(filePath string) (*streamBook, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open XLSX file: %w", err)
+	}
+
+	b := &streamBook{
+		zip:     archive,
+		files:   make(map[string]*zip.File, len(archive.File)),
+		targets: make(map[string]string),
+	}
+	for _, f := range archive.File {
+		b.files[strings.ToLower(f.Name)] = f
+	}
+
+	if err := b.readWorkbook(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// part opens an archive member
+func (b *streamBook) part(name string) (io.ReadCloser, error) {
+	f, ok := b.files[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("invalid XLSX file: %s is missing", name)
+	}
+	return f.Open()
+}
+
+// readWorkbook reads the sheet list and relationships, then indexes the
+// shared strings
+func (b *streamBook) readWorkbook() error {
+	// Relationship ids to part names
+	rels := make(map[string]string)
+	sharedPart := "xl/sharedStrings.xml"
+	stylesPart := "xl/styles.xml"
+	err := b.decodePart("xl/_rels/workbook.xml.rels", func(start xml.StartElement) error {
+		if start.Name.Local != "Relationship" {
+			return nil
+		}
+		id, target, kind := attr(start, "Id"), attr(start, "Target"), attr(start, "Type")
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		rels[id] = target
+		switch {
+		case strings.HasSuffix(kind, "/sharedStrings"):
+			sharedPart = target
+		case strings.HasSuffix(kind, "/styles"):
+			stylesPart = target
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = b.decodePart("xl/workbook.xml", func(start xml.StartElement) error {
+		switch start.Name.Local {
+		case "workbookPr":
+			value := attr(start, "date1904")
+			b.date1904 = value == "1" || value == "true"
+		case "sheet":
+			name := attr(start, "name")
+			for _, a := range start.Attr {
+				// r:id, as opposed to sheetId
+				if a.Name.Local == "id" && a.Name.Space != "" {
+					b.targets[name] = rels[a.Value]
+				}
+			}
+			b.sheets = append(b.sheets, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := b.readStyles(stylesPart); err != nil {
+		return err
+	}
+
+	b.strings, err = readSharedStrings(b, sharedPart)
+	return err
+}
+
+// readStyles reads the number format of each cell format and finds those
+// that are a date or time. A workbook without styles has none.
+func (b *streamBook) readStyles(name string) error {
+	if _, ok := b.files[strings.ToLower(name)]; !ok {
+		return nil
+	}
+
+	custom := make(map[int]string)
+	var formats []int
+	inCellXfs := false
+	err := b.decodeElements(name, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "numFmt":
+				id, _ := strconv.Atoi(attr(t, "numFmtId"))
+				custom[id] = attr(t, "formatCode")
+			case "cellXfs":
+				inCellXfs = true
+			case "xf":
+				if inCellXfs {
+					id, _ := strconv.Atoi(attr(t, "numFmtId"))
+					formats = append(formats, id)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "cellXfs" {
+				inCellXfs = false
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	b.numFmts, b.custom = formats, custom
+	b.dates = make([]bool, len(formats))
+	for i, id := range formats {
+		if code, ok := custom[id]; ok {
+			b.dates[i] = isDateFormatCode(code)
+		} else {
+			b.dates[i] = isDateFormatID(id)
+		}
+	}
+	return nil
+}
+
+// isDateStyle reports whether a cell format displays a date or time
+func (b *streamBook) isDateStyle(style int) bool {
+	return style >= 0 && style < len(b.dates) && b.dates[style]
+}
+
+// decodePart calls fn for every start element of an XML part
+func (b *streamBook) decodePart(name string, fn func(xml.StartElement) error) error {
+	var fnErr error
+	err := b.decodeElements(name, func(tok xml.Token) {
+		if start, ok := tok.(xml.StartElement); ok && fnErr == nil {
+			fnErr = fn(start)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// decodeElements calls fn for every token of an XML part
+func (b *streamBook) decodeElements(name string, fn func(xml.Token)) error {
+	r, err := b.part(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dec := xml.NewDecoder(bufio.NewReaderSize(r, 64*1024))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid XLSX file: %s: %w", name, err)
+		}
+		fn(tok)
+	}
+}
+
+// SheetList returns the sheet names in workbook order
+func (b *streamBook) SheetList() []string {
+	return b.sheets
+}
+
+// Rows starts streaming a worksheet
+func (b *streamBook) Rows(sheet string) (sheetReader, error) {
+	target, ok := b.targets[sheet]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s not found", sheet)
+	}
+	r, err := b.part(target)
+	if err != nil {
+		return nil, err
+	}
+	return &streamRows{
+		book:   b,
+		part:   r,
+		dec:    xml.NewDecoder(bufio.NewReaderSize(r, 256*1024)),
+		nextAt: -1,
+	}, nil
+}
+
+// Close closes the archive and removes the shared string file
+func (b *streamBook) Close() error {
+	if b.strings != nil {
+		b.strings.Close()
+	}
+	if b.format != nil {
+		b.format.file.Close()
+	}
+	return b.zip.Close()
+}
+
+// streamRows decodes the rows of a worksheet one at a time. Rows missing
+// from the XML are returned empty, so every Next is one sheet row.
+type streamRows struct {
+	book   *streamBook
+	part   io.ReadCloser
+	dec    *xml.Decoder
+	row    int64     // Sheet row of the current cells
+	cells  []rawCell // Current row
+	next   []rawCell // Row read ahead of a gap
+	nextAt int64     // Sheet row of next (-1 = none read, 0 = end of sheet)
+	err    error
+}
+
+// rawCell is a cell as stored in the worksheet XML
+type rawCell struct {
+	kind  string // The t attribute: s, str, inlineStr, b, e or n ("")
+	style int    // Index into the cell formats
+	value string // Stored value, or the text of an inline string
+	empty bool   // No value stored
+}
+
+// Next moves to the next sheet row
+func (s *streamRows) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.nextAt < 0 {
+		s.nextAt, s.next, s.err = s.readRow(s.row)
+		if s.err != nil {
+			return false
+		}
+	}
+	if s.nextAt == 0 {
+		return false
+	}
+
+	s.row++
+	if s.row < s.nextAt {
+		s.cells = nil
+		return true
+	}
+	s.cells = s.next
+	s.nextAt = -1
+	return true
+}
+
+// Columns returns the text of the cells in the current row. Numbers are
+// shown with their number format, as the excelize reader shows them.
+func (s *streamRows) Columns() ([]string, error) {
+	texts := make([]string, len(s.cells))
+	for i, cell := range s.cells {
+		text, err := s.cellText(cell)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", s.row, err)
+		}
+		texts[i] = text
+	}
+	return texts, nil
+}
+
+// Values returns the cells of the current row with their types: float64
+// for numbers, time.Time for numbers with a date format, bool, and string
+// for text and errors. Formulas give their cached result; empty cells nil.
+func (s *streamRows) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(s.cells))
+	for i, cell := range s.cells {
+		value, err := s.cellValue(cell)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", s.row, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// Error returns the error that stopped Next, if any
+func (s *streamRows) Error() error {
+	return s.err
+}
+
+// Close closes the worksheet part
+func (s *streamRows) Close() error {
+	return s.part.Close()
+}
+
+// readRow reads the next <row> element and returns its sheet row number
+// and cells, or row 0 at the end of the sheet data
+func (s *streamRows) readRow(previous int64) (int64, []rawCell, error) {
+	for {
+		tok, err := s.dec.Token()
+		if err == io.EOF {
+			return 0, nil, nil
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid worksheet XML: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		row := previous + 1
+		if r := attr(start, "r"); r != "" {
+			if row, err = strconv.ParseInt(r, 10, 64); err != nil || row <= previous {
+				return 0, nil, fmt.Errorf("invalid worksheet XML: row %q out of order", r)
+			}
+		}
+
+		cells, err := s.readCells()
+		if err != nil {
+			return 0, nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		return row, cells, nil
+	}
+}
+
+// readCells reads the cells of a row whose start element has been read
+func (s *streamRows) readCells() ([]rawCell, error) {
+	var cells []rawCell
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid worksheet XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			col := len(cells)
+			if ref := attr(t, "r"); ref != "" {
+				if col, err = columnIndex(ref); err != nil {
+					return nil, err
+				}
+			}
+			cell, err := s.readCell(t)
+			if err != nil {
+				return nil, err
+			}
+			for len(cells) <= col {
+				cells = append(cells, rawCell{empty: true})
+			}
+			cells[col] = cell
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return cells, nil
+			}
+		}
+	}
+}
+
+// readCell reads a cell whose start element has been read
+func (s *streamRows) readCell(start xml.StartElement) (rawCell, error) {
+	cell := rawCell{kind: attr(start, "t"), empty: true}
+	if style := attr(start, "s"); style != "" {
+		cell.style, _ = strconv.Atoi(style)
+	}
+
+	var value strings.Builder
+	var inValue, inText bool
+	phonetic := 0
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return cell, fmt.Errorf("invalid worksheet XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "v":
+				inValue = true
+				cell.empty = false
+			case "is":
+				cell.empty = false
+			case "t":
+				inText = true
+			case "rPh":
+				phonetic++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v":
+				inValue = false
+			case "t":
+				inText = false
+			case "rPh":
+				phonetic--
+			case "c":
+				cell.value = value.String()
+				return cell, nil
+			}
+		case xml.CharData:
+			// An inline string has no <v>, so the two never mix
+			if inValue || (inText && phonetic == 0) {
+				value.Write(t)
+			}
+		}
+	}
+}
+
+// cellText turns a stored cell value into text by cell type
+func (s *streamRows) cellText(cell rawCell) (string, error) {
+	switch cell.kind {
+	case "s":
+		if cell.empty {
+			return "", nil
+		}
+		return s.book.strings.lookup(cell.value)
+	case "b":
+		if cell.empty {
+			return "", nil
+		}
+		if cell.value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		return s.book.formatNumber(cell)
+	default:
+		return cell.value, nil
+	}
+}
+
+// cellValue turns a stored cell value into a typed value
+func (s *streamRows) cellValue(cell rawCell) (interface{}, error) {
+	if cell.empty {
+		return nil, nil
+	}
+	switch cell.kind {
+	case "s":
+		return s.book.strings.lookup(cell.value)
+	case "str", "inlineStr", "e":
+		return cell.value, nil
+	case "b":
+		return cell.value == "1", nil
+	default:
+		n, err := strconv.ParseFloat(strings.TrimSpace(cell.value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", cell.value)
+		}
+		if s.book.isDateStyle(cell.style) {
+			return excelTime(n, s.book.date1904), nil
+		}
+		return n, nil
+	}
+}
+
+// formatNumber shows a stored number the way excelize does: rounded to 15
+// significant digits, then through the number format of its cell format
+func (b *streamBook) formatNumber(cell rawCell) (string, error) {
+	n, err := strconv.ParseFloat(cell.value, 64)
+	if err != nil || strings.Contains(cell.value, "_") {
+		return cell.value, nil
+	}
+	value := strconv.FormatFloat(n, 'f', -1, 64)
+	if len(strings.ReplaceAll(value, ".", "")) > 15 {
+		value = strconv.FormatFloat(n, 'G', 15, 64)
+	}
+
+	if cell.style <= 0 || cell.style >= len(b.numFmts) {
+		return value, nil
+	}
+	if b.format == nil {
+		format, err := newNumberFormatter(b.date1904)
+		if err != nil {
+			return "", err
+		}
+		b.format = format
+	}
+	return b.format.apply(value, b.numFmts[cell.style], b.custom)
+}
+
+// numberFormatter applies number formats through a scratch excelize
+// workbook, so the streaming reader formats cells exactly as the excelize
+// reader does. Each number format gets a scratch cell styled with it.
+type numberFormatter struct {
+	file  *excelize.File
+	cells map[int]string // Scratch cell by number format id ("" = unusable)
+}
+
+// newNumberFormatter creates the scratch workbook
+func newNumberFormatter(date1904 bool) (*numberFormatter, error) {
+	file := excelize.NewFile()
+	if err := file.SetWorkbookProps(&excelize.WorkbookPropsOptions{Date1904: &date1904}); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to set up number formats: %w", err)
+	}
+	return &numberFormatter{file: file, cells: make(map[int]string)}, nil
+}
+
+// apply formats a number with number format id, whose code is in custom
+// unless it is a built-in format
+func (f *numberFormatter) apply(value string, id int, custom map[int]string) (string, error) {
+	const sheet = "Sheet1"
+	cell, ok := f.cells[id]
+	if !ok {
+		spec := &excelize.Style{NumFmt: id}
+		if code, ok := custom[id]; ok {
+			spec = &excelize.Style{CustomNumFmt: &code}
+		}
+		// A format excelize rejects leaves its numbers unformatted
+		if style, err := f.file.NewStyle(spec); err == nil {
+			cell, _ = excelize.CoordinatesToCellName(1, len(f.cells)+1)
+			if err := f.file.SetCellStyle(sheet, cell, cell, style); err != nil {
+				return "", fmt.Errorf("failed to apply number format %d: %w", id, err)
+			}
+		}
+		f.cells[id] = cell
+	}
+	if cell == "" {
+		return value, nil
+	}
+
+	if err := f.file.SetCellDefault(sheet, cell, value); err != nil {
+		return "", fmt.Errorf("failed to apply number format %d: %w", id, err)
+	}
+	return f.file.GetCellValue(sheet, cell)
+}
+
+// attr returns the value of an attribute by local name
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// columnIndex returns the zero-based column of a cell reference such as AB12
+func columnIndex(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref); i++ {
+		c := ref[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+	}
+	if i == 0 || col > 16384 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+// sharedStrings holds the workbook's shared string table in a temporary
+// file, with the most recently used strings cached in memory
+type sharedStrings struct {
+	file    *os.File
+	offsets []int64 // String i is file[offsets[i]:offsets[i+1])
+	cache   map[int]*list.Element
+	recent  *list.List // Cached entries, most recently used first
+}
+
+// cachedString is an entry in the shared string cache
+type cachedString struct {
+	index int
+	value string
+}
+
+// readSharedStrings copies the shared string part into a temporary file.
+// Workbooks without shared strings get an empty table.
+func readSharedStrings(b *streamBook, name string) (*sharedStrings, error) {
+	table := &sharedStrings{
+		offsets: []int64{0},
+		cache:   make(map[int]*list.Element),
+		recent:  list.New(),
+	}
+	if _, ok := b.files[strings.ToLower(name)]; !ok {
+		return table, nil
+	}
+
+	file, err := os.CreateTemp("", "data-engine-strings-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shared string file: %w", err)
+	}
+	table.file = file
+	out := bufio.NewWriterSize(file, 256*1024)
+
+	var text strings.Builder
+	inText := false
+	phonetic := 0
+	offset := int64(0)
+	r, err := b.part(name)
+	if err != nil {
+		table.Close()
+		return nil, err
+	}
+	defer r.Close()
+
+	dec := xml.NewDecoder(bufio.NewReaderSize(r, 256*1024))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			table.Close()
+			return nil, fmt.Errorf("invalid XLSX file: %s: %w", name, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				text.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				phonetic++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				n, err := out.WriteString(text.String())
+				if err != nil {
+					table.Close()
+					return nil, fmt.Errorf("failed to write shared string file: %w", err)
+				}
+				offset += int64(n)
+				table.offsets = append(table.offsets, offset)
+			case "t":
+				inText = false
+			case "rPh":
+				phonetic--
+			}
+		case xml.CharData:
+			if inText && phonetic == 0 {
+				text.Write(t)
+			}
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		table.Close()
+		return nil, fmt.Errorf("failed to write shared string file: %w", err)
+	}
+	return table, nil
+}
+
+// lookup returns the shared string a cell value refers to
+func (t *sharedStrings) lookup(value string) (string, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid shared string index %q", value)
+	}
+	return t.get(i)
+}
+
+// get returns shared string i
+func (t *sharedStrings) get(i int) (string, error) {
+	if i < 0 || i >= len(t.offsets)-1 {
+		return "", fmt.Errorf("shared string index %d out of range", i)
+	}
+	if elem, ok := t.cache[i]; ok {
+		t.recent.MoveToFront(elem)
+		return elem.Value.(*cachedString).value, nil
+	}
+
+	buf := make([]byte, t.offsets[i+1]-t.offsets[i])
+	if _, err := t.file.ReadAt(buf, t.offsets[i]); err != nil {
+		return "", fmt.Errorf("failed to read shared string file: %w", err)
+	}
+	value := string(buf)
+
+	// Evict the least recently used string
+	if t.recent.Len() >= sharedStringCache {
+		oldest := t.recent.Back()
+		t.recent.Remove(oldest)
+		delete(t.cache, oldest.Value.(*cachedString).index)
+	}
+	t.cache[i] = t.recent.PushFront(&cachedString{index: i, value: value})
+
+	return value, nil
+}
+
+// Close removes the temporary file
+func (t *sharedStrings) Close() error {
+	if t.file == nil {
+		return nil
+	}
+	t.file.Close()
+	return os.Remove(t.file.Name())
+}
+
+// isDateFormatID reports whether a built-in number format is a date or time
+func isDateFormatID(id int) bool {
+	return (id >= 14 && id <= 22) || (id >= 27 && id <= 36) || (id >= 45 && id <= 47) || (id >= 50 && id <= 58)
+}
+
+// isDateFormatCode reports whether a custom number format shows a date or
+// time: it has y, m, d, h or s outside quoted text, escapes and bracketed
+// colors or locales. Only the first section, for positive numbers, counts.
+func isDateFormatCode(code string) bool {
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '"':
+			end := strings.IndexByte(code[i+1:], '"')
+			if end < 0 {
+				return false
+			}
+			i += end + 1
+		case '\\', '_', '*':
+			i++ // The next character is literal or padding
+		case '[':
+			end := strings.IndexByte(code[i:], ']')
+			if end < 0 {
+				return false
+			}
+			// Elapsed time such as [h] or [mm]
+			if inner := strings.ToLower(code[i+1 : i+end]); inner != "" && strings.Trim(inner, "hms") == "" {
+				return true
+			}
+			i += end
+		case ';':
+			return false
+		default:
+			switch c | 0x20 {
+			case 'y', 'm', 'd', 'h', 's':
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excelTime converts a date serial number to a time. The 1900 system counts
+// the nonexistent 29 February 1900 as day 60, so serials before it are a
+// day off from those after.
+func excelTime(serial float64, date1904 bool) time.Time {
+	var epoch time.Time
+	switch {
+	case date1904:
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	case serial < 61:
+		epoch = time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC)
+	default:
+		epoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	}
+
+	// Whole days separately, as a Duration cannot span the full date range
+	days := math.Floor(serial)
+	millis := math.Round((serial - days) * 24 * 60 * 60 * 1000)
+	return epoch.AddDate(0, 0, int(days)).Add(time.Duration(millis) * time.Millisecond)
+}
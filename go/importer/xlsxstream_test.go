@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExcelTime(t *testing.T) {
+	date := func(y int, m time.Month, d, h, min, s, ms int) time.Time {
+		return time.Date(y, m, d, h, min, s, ms*int(time.Millisecond), time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		serial   float64
+		date1904 bool
+		want     time.Time
+	}{
+		{"1900 zero", 0, false, date(1899, 12, 31, 0, 0, 0, 0)},
+		{"1900 first day", 1, false, date(1900, 1, 1, 0, 0, 0, 0)},
+		{"last day before leap bug", 59, false, date(1900, 2, 28, 0, 0, 0, 0)},
+		{"nonexistent 29 February", 60, false, date(1900, 3, 1, 0, 0, 0, 0)},
+		{"first day after leap bug", 61, false, date(1900, 3, 1, 0, 0, 0, 0)},
+		{"noon before leap bug", 59.5, false, date(1900, 2, 28, 12, 0, 0, 0)},
+		{"modern date and time", 45123.75, false, date(2023, 7, 16, 18, 0, 0, 0)},
+		{"rounded to the second", 45123.0000115741, false, date(2023, 7, 16, 0, 0, 1, 0)},
+		{"sub-second", 0.1234, false, date(1899, 12, 31, 2, 57, 41, 760)},
+		{"far future", 2958465, false, date(9999, 12, 31, 0, 0, 0, 0)},
+		{"1904 zero", 0, true, date(1904, 1, 1, 0, 0, 0, 0)},
+		{"1904 no leap bug", 60, true, date(1904, 3, 1, 0, 0, 0, 0)},
+		{"1904 modern date", 43661.75, true, date(2023, 7, 16, 18, 0, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excelTime(tt.serial, tt.date1904); !got.Equal(tt.want) {
+				t.Errorf("excelTime(%v, %v) = %v, want %v", tt.serial, tt.date1904, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"yyyy-mm-dd", true},
+		{"d/m/yy h:mm", true},
+		{"h:mm AM/PM", true},
+		{"mmm d, yyyy", true},
+		{"[h]:mm", true},
+		{"[mm]:ss", true},
+		{"[ss]", true},
+		{"[$-409]mmmm d, yyyy", true},
+		{"General", false},
+		{"@", false},
+		{"0.00", false},
+		{"#,##0.00_);(#,##0.00)", false},
+		{`0.00" days"`, false},
+		{`"Total: "#,##0`, false},
+		{`"unterminated`, false},
+		{"[Red]0.00", false},
+		{"[$-409]0.00", false},
+		{"#,##0.00 [$€-407]", false},
+		{`0\d`, false},
+		{"0_s", false},
+		{"*s0", false},
+		{"0;[Red]dd", false},
+		{"0;-0;0;@ \"d\"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isDateFormatCode(tt.code); got != tt.want {
+			t.Errorf("isDateFormatCode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package textfile
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// QuoteNone is the quote setting of files whose fields are never quoted
+const QuoteNone = "none"
+
+// Quote escaping inside quoted fields
+const (
+	EscapeDouble    = "double"    // A quote is written twice: "a ""b"""
+	EscapeBackslash = "backslash" // A backslash escapes the next character: "a \"b\""
+)
+
+// Rune returns the single character of a dialect setting, or def when the
+// setting is empty
+func Rune(value string, def rune) rune {
+	if value == "" {
+		return def
+	}
+	ch, _ := utf8.DecodeRuneInString(value)
+	return ch
+}
+
+// QuoteRune returns the quote character of a quote setting: the double
+// quote when it is empty and 0 for QuoteNone
+func QuoteRune(value string) rune {
+	if value == QuoteNone {
+		return 0
+	}
+	return Rune(value, '"')
+}
+
+// LookupEncoding returns the character encoding with an IANA name or alias,
+// such as windows-1252, ISO-8859-1, latin1 or UTF-16LE, or a WHATWG label
+// such as cp1252 or utf8
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		enc, err = htmlindex.Get(name)
+	}
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return enc, nil
+}
@@ -8,17 +8,45 @@ import (
 
 // Batch represents a batch of rows to be processed
 type Batch struct {
-	Rows [][]interface{}
-	Err  error
+	Seq   int64 // Sequence number in submission order
+	Rows  [][]interface{}
+	Lines []int64 // Source line of each row, parallel to Rows
+	First int64   // Input position of the first row
+	Last  int64   // Input position of the last row
+	Err   error
+}
+
+// Span is an inclusive range of input positions
+type Span struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+}
+
+// record is a submitted row together with its input position and source line
+type record struct {
+	pos    int64
+	line   int64
+	values []interface{}
+}
+
+// commitTracker follows completed batches so progress can be reported as
+// the position up to which every submitted row has been committed
+type commitTracker struct {
+	mu        sync.Mutex
+	next      int64          // Lowest sequence number not yet completed
+	committed int64          // Position of the last row before next
+	done      map[int64]Span // Completed batches beyond next
+	onCommit  func(committed int64, pending []Span)
 }
 
 // Pool manages a pool of worker goroutines for concurrent processing
 type Pool struct {
 	workers   int
 	batchSize int
-	inputCh   chan []interface{}
+	inputCh   chan record
 	batchCh   chan Batch
 	errorCh   chan error
+	commits   commitTracker
 	wg        sync.WaitGroup
 	ctx       context.Context
 	cancel    context.CancelFunc
@@ -27,20 +55,29 @@ type Pool struct {
 // NewPool creates a new worker pool
 func NewPool(ctx context.Context, workers, batchSize int) *Pool {
 	ctx, cancel := context.WithCancel(ctx)
-	
+
 	return &Pool{
 		workers:   workers,
 		batchSize: batchSize,
-		inputCh:   make(chan []interface{}, workers*2), // Buffered for backpressure
+		inputCh:   make(chan record, workers*2), // Buffered for backpressure
 		batchCh:   make(chan Batch, workers),
 		errorCh:   make(chan error, 1),
+		commits:   commitTracker{done: make(map[int64]Span)},
 		ctx:       ctx,
 		cancel:    cancel,
 	}
 }
 
+// OnCommit registers fn to be called after every completed batch. committed
+// is the input position of the last row in the run of contiguously completed
+// batches; pending lists batches completed out of order beyond it. Calls are
+// serialized. Must be called before Start.
+func (p *Pool) OnCommit(fn func(committed int64, pending []Span)) {
+	p.commits.onCommit = fn
+}
+
 // Start starts the worker pool
-func (p *Pool) Start(processBatch func(context.Context, [][]interface{}) error) {
+func (p *Pool) Start(processBatch func(context.Context, Batch) error) {
 	// Start batch accumulator
 	p.wg.Add(1)
 	go p.accumulator()
@@ -58,33 +95,44 @@ func (p *Pool) accumulator() {
 	defer close(p.batchCh)
 
 	batch := make([][]interface{}, 0, p.batchSize)
+	lines := make([]int64, 0, p.batchSize)
+	var seq, first, last int64
 
 	flush := func() {
 		if len(batch) > 0 {
 			// Make a copy to avoid race conditions
 			batchCopy := make([][]interface{}, len(batch))
 			copy(batchCopy, batch)
-			
+			linesCopy := make([]int64, len(lines))
+			copy(linesCopy, lines)
+
 			select {
-			case p.batchCh <- Batch{Rows: batchCopy}:
+			case p.batchCh <- Batch{Seq: seq, Rows: batchCopy, Lines: linesCopy, First: first, Last: last}:
 			case <-p.ctx.Done():
 				return
 			}
-			
+
 			batch = batch[:0] // Reset batch
+			lines = lines[:0]
+			seq++
 		}
 	}
 
 	for {
 		select {
-		case row, ok := <-p.inputCh:
+		case rec, ok := <-p.inputCh:
 			if !ok {
 				// Input channel closed, flush remaining batch
 				flush()
 				return
 			}
-			
-			batch = append(batch, row)
+
+			if len(batch) == 0 {
+				first = rec.pos
+			}
+			last = rec.pos
+			batch = append(batch, rec.values)
+			lines = append(lines, rec.line)
 			if len(batch) >= p.batchSize {
 				flush()
 			}
@@ -96,7 +144,7 @@ func (p *Pool) accumulator() {
 }
 
 // worker processes batches
-func (p *Pool) worker(id int, processBatch func(context.Context, [][]interface{}) error) {
+func (p *Pool) worker(id int, processBatch func(context.Context, Batch) error) {
 	defer p.wg.Done()
 
 	for {
@@ -106,7 +154,7 @@ func (p *Pool) worker(id int, processBatch func(context.Context, [][]interface{}
 				return
 			}
 
-			if err := processBatch(p.ctx, batch.Rows); err != nil {
+			if err := processBatch(p.ctx, batch); err != nil {
 				// Send error and cancel context
 				select {
 				case p.errorCh <- fmt.Errorf("worker %d: %w", id, err):
@@ -115,6 +163,7 @@ func (p *Pool) worker(id int, processBatch func(context.Context, [][]interface{}
 				p.cancel()
 				return
 			}
+			p.commits.complete(batch)
 
 		case <-p.ctx.Done():
 			return
@@ -122,10 +171,11 @@ func (p *Pool) worker(id int, processBatch func(context.Context, [][]interface{}
 	}
 }
 
-// Submit submits a row to the pool
-func (p *Pool) Submit(row []interface{}) error {
+// Submit submits a row to the pool. pos is the row's position in the input
+// (increasing with every row read) and line its source line.
+func (p *Pool) Submit(pos, line int64, row []interface{}) error {
 	select {
-	case p.inputCh <- row:
+	case p.inputCh <- record{pos: pos, line: line, values: row}:
 		return nil
 	case <-p.ctx.Done():
 		return p.ctx.Err()
@@ -150,3 +200,32 @@ func (p *Pool) Close() error {
 func (p *Pool) Cancel() {
 	p.cancel()
 }
+
+// complete records a processed batch, advances the contiguous run and
+// reports progress
+func (t *commitTracker) complete(batch Batch) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done[batch.Seq] = Span{First: batch.First, Last: batch.Last}
+	for {
+		span, ok := t.done[t.next]
+		if !ok {
+			break
+		}
+		t.committed = span.Last
+		delete(t.done, t.next)
+		t.next++
+	}
+
+	if t.onCommit == nil {
+		return
+	}
+	pending := make([]Span, 0, len(t.done))
+	for seq := t.next; len(pending) < len(t.done); seq++ {
+		if span, ok := t.done[seq]; ok {
+			pending = append(pending, span)
+		}
+	}
+	t.onCommit(t.committed, pending)
+}
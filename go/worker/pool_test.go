@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+)
+
+// commitCall is one onCommit report
+type commitCall struct {
+	committed int64
+	pending   []Span
+}
+
+func TestCommitTrackerOutOfOrder(t *testing.T) {
+	// Batches of ten positions each: seq 0 = 1-10, seq 1 = 11-20, ...
+	batch := func(seq int64) Batch {
+		return Batch{Seq: seq, First: seq*10 + 1, Last: seq*10 + 10}
+	}
+
+	tests := []struct {
+		name  string
+		order []int64
+		want  []commitCall
+	}{
+		{
+			name:  "in order",
+			order: []int64{0, 1, 2},
+			want: []commitCall{
+				{10, []Span{}},
+				{20, []Span{}},
+				{30, []Span{}},
+			},
+		},
+		{
+			name:  "first batch last",
+			order: []int64{2, 1, 0},
+			want: []commitCall{
+				{0, []Span{{21, 30}}},
+				{0, []Span{{11, 20}, {21, 30}}},
+				{30, []Span{}},
+			},
+		},
+		{
+			name:  "gap filled in the middle",
+			order: []int64{0, 2, 4, 1, 3},
+			want: []commitCall{
+				{10, []Span{}},
+				{10, []Span{{21, 30}}},
+				{10, []Span{{21, 30}, {41, 50}}},
+				{30, []Span{{41, 50}}},
+				{50, []Span{}},
+			},
+		},
+		{
+			name:  "committed never passes a gap",
+			order: []int64{1, 3, 2},
+			want: []commitCall{
+				{0, []Span{{11, 20}}},
+				{0, []Span{{11, 20}, {31, 40}}},
+				{0, []Span{{11, 20}, {21, 30}, {31, 40}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []commitCall
+			tracker := commitTracker{done: make(map[int64]Span)}
+			tracker.onCommit = func(committed int64, pending []Span) {
+				got = append(got, commitCall{committed, pending})
+			}
+			for _, seq := range tt.order {
+				tracker.complete(batch(seq))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reports = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitTrackerUnevenBatches(t *testing.T) {
+	// Skipped rows leave gaps between positions of consecutive batches
+	tracker := commitTracker{done: make(map[int64]Span)}
+	var committed int64
+	tracker.onCommit = func(c int64, _ []Span) { committed = c }
+
+	tracker.complete(Batch{Seq: 1, First: 8, Last: 9})
+	if committed != 0 {
+		t.Fatalf("committed = %d after an out-of-order batch, want 0", committed)
+	}
+	tracker.complete(Batch{Seq: 0, First: 1, Last: 5})
+	if committed != 9 {
+		t.Fatalf("committed = %d, want 9", committed)
+	}
+}
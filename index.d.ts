@@ -6,13 +6,134 @@
 /**
  * Supported input file formats for import operations
  */
-export type InputFormat = 'auto' | 'csv' | 'tsv' | 'jsonl' | 'xlsx';
+export type InputFormat = 'auto' | 'csv' | 'tsv' | 'jsonl' | 'json' | 'xlsx' | 'parquet';
+
+/**
+ * Methods used to write batches into the database
+ * - insert: multi-value INSERT statements (all databases)
+ * - copy: PostgreSQL COPY FROM STDIN, text format
+ * - copy-binary: PostgreSQL COPY FROM STDIN, binary format; needs type coercion
+ * - load-data: MySQL LOAD DATA LOCAL INFILE (server needs local_infile enabled)
+ */
+export type InsertMethod = 'insert' | 'copy' | 'copy-binary' | 'load-data';
+
+/**
+ * Compression of the input file
+ * - auto: from the extension (e.g. .csv.gz, .jsonl.zst) or the magic bytes
+ * - zip: an archive of one or more data files with the same layout, read in order
+ */
+export type InputCompression = 'auto' | 'none' | 'gzip' | 'zstd' | 'bzip2' | 'xz' | 'zip';
+
+/**
+ * How JSONL columns are discovered
+ * - first-line: keys of the first object
+ * - sample: keys of the first `jsonlDiscoverySample` lines
+ * - full: keys of every line (reads the file twice)
+ */
+export type JSONLDiscovery = 'first-line' | 'sample' | 'full';
+
+/**
+ * How nested JSONL objects and arrays are imported
+ * - json: keep them as JSON text, for jsonb/JSON or text columns
+ * - flatten: one column per object path, e.g. user_address_city
+ */
+export type JSONLNested = 'json' | 'flatten';
+
+/**
+ * How arrays are imported when flattening
+ * - json: keep them as JSON text
+ * - explode: one row per element; several arrays in a row multiply, up to
+ *   10000 rows per object
+ */
+export type JSONLArrays = 'json' | 'explode';
+
+/**
+ * Which sheets of an XLSX workbook are imported
+ * - single: one sheet, chosen by xlsxSheet or xlsxSheetIndex (default: the first)
+ * - combined: every sheet into the table, with the sheet name in xlsxSheetColumn
+ * - per-sheet: every sheet into its own table, named by xlsxTableTemplate
+ */
+export type XLSXSheets = 'single' | 'combined' | 'per-sheet';
+
+/**
+ * How XLSX workbooks are read
+ * - auto: excelize up to xlsxMaxSize, stream above it
+ * - excelize: load the workbook with excelize, refusing files over xlsxMaxSize
+ * - stream: read worksheet XML straight from the zip, with shared strings
+ *   spilled to a temporary file; memory stays flat for multi-GB workbooks
+ */
+export type XLSXReader = 'auto' | 'excelize' | 'stream';
+
+/**
+ * How quotes are escaped inside quoted CSV fields
+ * - double: written twice, "a ""b"""
+ * - backslash: preceded by a backslash, "a \"b\"" (a backslash escapes any character)
+ */
+export type CSVEscape = 'double' | 'backslash';
+
+/**
+ * CSV rows whose field count differs from the header
+ * - error: rejected
+ * - pad: short rows padded with NULL, long rows rejected
+ * - truncate: short rows padded with NULL, extra fields dropped
+ */
+export type CSVRagged = 'error' | 'pad' | 'truncate';
+
+/**
+ * What to do when an imported row collides with an existing key
+ * - error: fail the import (plain INSERT)
+ * - skip: keep the existing row (ON CONFLICT DO NOTHING / INSERT IGNORE)
+ * - update-all: overwrite every non-key column
+ * - update-selected: overwrite only `updateColumns`
+ */
+export type ConflictStrategy = 'error' | 'skip' | 'update-all' | 'update-selected';
+
+/**
+ * What happens to the rows already in the target table
+ * - append: keep them
+ * - truncate: remove every row before loading
+ * - delete-where: remove rows matching `deleteWhere` before loading
+ * - swap: load a shadow copy of the table and rename it over the original
+ */
+export type LoadStrategy = 'append' | 'truncate' | 'delete-where' | 'swap';
+
+/**
+ * Mapping from file header columns to table columns
+ */
+export interface ColumnMapping {
+  /**
+   * Rename source columns: { "First Name": "first_name" }
+   */
+  rename?: Record<string, string>;
+
+  /**
+   * Source columns to leave out of the import
+   */
+  drop?: string[];
+
+  /**
+   * Target columns to place first, in this order.
+   * Remaining columns keep file order, followed by constants.
+   */
+  order?: string[];
+
+  /**
+   * Target columns filled with the same value for every row
+   */
+  constants?: Record<string, string | number | boolean | null>;
+}
 
 /**
  * Supported output file formats for export operations
  */
 export type OutputFormat = 'csv' | 'tsv' | 'jsonl' | 'parquet';
 
+/**
+ * Compression of csv, tsv and jsonl output
+ * - auto: from the output extension (.gz, .zst), otherwise none
+ */
+export type OutputCompression = 'auto' | 'none' | 'gzip' | 'zstd';
+
 /**
  * Options for importing data from a file into a database
  */
@@ -24,6 +145,8 @@ export interface ImportOptions {
 
   /**
    * File format. Use 'auto' to auto-detect from file extension and content.
+   * The delimiter and header of delimited text are sniffed from its first
+   * rows and logged; set format, csvDelimiter and csvHeader to pin them.
    * @default 'auto'
    */
   format?: InputFormat;
@@ -53,6 +176,314 @@ export interface ImportOptions {
    * @default 0
    */
   workers?: number;
+
+  /**
+   * Compression of the input file. csv, tsv, jsonl and json input is read
+   * through a streaming decompressor; parseWorkers is ignored for it.
+   * @default 'auto'
+   */
+  compression?: InputCompression;
+
+  /**
+   * Character encoding of csv, tsv, jsonl and json input, e.g. 'windows-1252',
+   * 'ISO-8859-1' (latin1) or 'UTF-16LE'. 'auto' reads UTF-8, or UTF-16 when
+   * the file starts with a byte order mark; byte order marks are stripped
+   * either way. parseWorkers is ignored for re-encoded input.
+   * @default 'auto'
+   */
+  encoding?: string;
+
+  /**
+   * How batches are written to the database.
+   * The COPY methods (PostgreSQL) and load-data (MySQL) are much faster for large loads.
+   * @default 'insert'
+   */
+  insertMethod?: InsertMethod;
+
+  /**
+   * Conflict handling for rows that collide with an existing key.
+   * Update strategies require the 'insert' method.
+   * @default 'error'
+   */
+  onConflict?: ConflictStrategy;
+
+  /**
+   * Key columns that identify a duplicate row.
+   * Required for 'update-all' and 'update-selected'.
+   */
+  conflictKeys?: string[];
+
+  /**
+   * Columns to overwrite when onConflict is 'update-selected'
+   */
+  updateColumns?: string[];
+
+  /**
+   * Map file header columns to table columns.
+   * Validated against the header before any rows are loaded.
+   */
+  columnMapping?: ColumnMapping;
+
+  /**
+   * Skip converting values to the target column types.
+   * By default the table is inspected and every field is converted
+   * (integers, decimals, booleans, dates, timestamps, bytes, JSON),
+   * empty strings become NULL outside text columns, and rows with
+   * unconvertible fields are rejected.
+   * @default false
+   */
+  disableTypeCoercion?: boolean;
+
+  /**
+   * Create the target table when it does not exist, using column types
+   * (integer, numeric, boolean, date, timestamp, text, json) inferred
+   * from the first rows of the input
+   * @default false
+   */
+  createTable?: boolean;
+
+  /**
+   * Number of rows sampled to infer column types
+   * @default 1000
+   */
+  createTableSample?: number;
+
+  /**
+   * Print the inferred CREATE TABLE statement to stdout without
+   * executing it or loading any data. Requires createTable.
+   * @default false
+   */
+  createTableDryRun?: boolean;
+
+  /**
+   * JSONL file receiving every rejected row (unparseable, unconvertible
+   * or refused by the database) with its line number and error message.
+   * Batches refused because of constraint or type errors are bisected so
+   * that only the offending rows are rejected.
+   */
+  rejectFile?: string;
+
+  /**
+   * Number of rejected rows tolerated before the import fails.
+   * With maxErrors and maxErrorRatio both 0, the first bad row fails the import.
+   * @default 0
+   */
+  maxErrors?: number;
+
+  /**
+   * Fraction of rejected rows (0-1) tolerated before the import fails
+   * @default 0
+   */
+  maxErrorRatio?: number;
+
+  /**
+   * File recording committed progress while the import runs.
+   * It is removed once the import completes.
+   */
+  checkpointFile?: string;
+
+  /**
+   * Continue from checkpointFile, skipping rows that were already committed.
+   * Fails if the input file or table differ from the checkpoint.
+   * @default false
+   */
+  resume?: boolean;
+
+  /**
+   * Load into a staging table and publish every row to the target in a single
+   * transaction at the end. A failed import leaves the target table untouched.
+   * Cannot be combined with checkpointFile.
+   * @default false
+   */
+  transactional?: boolean;
+
+  /**
+   * What happens to the rows already in the table.
+   * With transactional, truncate and delete-where run in the publishing transaction.
+   * @default 'append'
+   */
+  loadStrategy?: LoadStrategy;
+
+  /**
+   * SQL predicate selecting the rows to delete with 'delete-where',
+   * e.g. "load_date = '2024-01-31'"
+   */
+  deleteWhere?: string;
+
+  /**
+   * Goroutines parsing csv, tsv and jsonl input. The file is split into byte
+   * ranges aligned to record boundaries, so quoted multiline fields stay whole.
+   * Cannot be combined with checkpointFile.
+   * @default 1
+   */
+  parseWorkers?: number;
+
+  /**
+   * Input columns to read, in this order, instead of discovering them from the file.
+   * Keys outside the list are ignored. Supported for jsonl, json and parquet;
+   * for parquet only the listed columns are decoded.
+   */
+  columns?: string[];
+
+  /**
+   * How JSONL columns are discovered. Columns are ordered by first appearance.
+   * @default 'first-line'
+   */
+  jsonlDiscovery?: JSONLDiscovery;
+
+  /**
+   * Lines scanned when jsonlDiscovery is 'sample'
+   * @default 1000
+   */
+  jsonlDiscoverySample?: number;
+
+  /**
+   * How nested objects and arrays are imported
+   * @default 'json'
+   */
+  jsonlNested?: JSONLNested;
+
+  /**
+   * Joins the keys of flattened column names. Dotted names need a
+   * columnMapping rename unless the table uses them as quoted identifiers.
+   * @default '_'
+   */
+  jsonlSeparator?: '_' | '.';
+
+  /**
+   * Most keys in a flattened column name; deeper objects stay JSON text (0 = no limit)
+   * @default 0
+   */
+  jsonlMaxDepth?: number;
+
+  /**
+   * Arrays when flattening. Cannot explode with checkpointFile.
+   * @default 'json'
+   */
+  jsonlArrays?: JSONLArrays;
+
+  /**
+   * JSON pointer (RFC 6901) to the array of objects in a json file,
+   * e.g. '/data' or '/response/items'. Defaults to the top-level value.
+   * The jsonl* options apply to the array's objects.
+   */
+  jsonPointer?: string;
+
+  /**
+   * XLSX sheet to import, by name. Cannot be combined with xlsxSheetIndex.
+   */
+  xlsxSheet?: string;
+
+  /**
+   * XLSX sheet to import, by position starting at 1
+   */
+  xlsxSheetIndex?: number;
+
+  /**
+   * XLSX row holding the column names, starting at 1. Rows above it are skipped.
+   * @default 1
+   */
+  xlsxHeaderRow?: number;
+
+  /**
+   * First XLSX row with data. Defaults to the row after the header.
+   */
+  xlsxDataStartRow?: number;
+
+  /**
+   * Which sheets are imported. Cannot be combined with checkpointFile unless 'single'.
+   * @default 'single'
+   */
+  xlsxSheets?: XLSXSheets;
+
+  /**
+   * Column receiving the sheet name when xlsxSheets is 'combined'
+   * @default 'sheet_name'
+   */
+  xlsxSheetColumn?: string;
+
+  /**
+   * Table name for each sheet when xlsxSheets is 'per-sheet'. {table} is the
+   * table option, {sheet} the sheet name lowercased with other characters
+   * replaced by _, and {index} the sheet position, e.g. '{table}_{sheet}'.
+   */
+  xlsxTableTemplate?: string;
+
+  /**
+   * How the workbook is read. Both readers apply the cells' number formats,
+   * so dates and percentages come out as the workbook shows them.
+   * @default 'auto'
+   */
+  xlsxReader?: XLSXReader;
+
+  /**
+   * Largest workbook, in bytes, loaded by excelize
+   * @default 104857600
+   */
+  xlsxMaxSize?: number;
+
+  /**
+   * Read XLSX cells with their types instead of as displayed text: numbers,
+   * booleans, dates (date-formatted serials, using the workbook's 1900 or
+   * 1904 epoch) and the cached results of formulas. Uses the streaming reader.
+   * @default false
+   */
+  xlsxTypedValues?: boolean;
+
+  /**
+   * CSV/TSV field separator, one character, e.g. ';' or '|'
+   * @default ',' for csv, tab for tsv
+   */
+  csvDelimiter?: string;
+
+  /**
+   * Quote character, or 'none' for files whose fields are never quoted
+   * @default '"'
+   */
+  csvQuote?: string;
+
+  /**
+   * How quotes are escaped inside quoted fields
+   * @default 'double'
+   */
+  csvEscape?: CSVEscape;
+
+  /**
+   * Lines starting with this character are skipped
+   */
+  csvComment?: string;
+
+  /**
+   * Whether the first row holds the column names. Without a header the
+   * columns are named column_1, column_2, ...
+   * @default true
+   */
+  csvHeader?: boolean;
+
+  /**
+   * Lines skipped before the header, e.g. a report title
+   * @default 0
+   */
+  csvSkipLines?: number;
+
+  /**
+   * Rows with fewer or more fields than the header
+   * @default 'error'
+   */
+  csvRagged?: CSVRagged;
+
+  /**
+   * Keep the leading spaces of fields instead of trimming them
+   * @default false
+   */
+  csvKeepSpaces?: boolean;
+
+  /**
+   * Reject rows with a quote inside an unquoted field or a stray quote in a
+   * quoted one, instead of keeping the quote as text
+   * @default false
+   */
+  csvStrictQuotes?: boolean;
 }
 
 /**
@@ -94,6 +525,64 @@ export interface ExportOptions {
    * @default 0
    */
   workers?: number;
+
+  /**
+   * Compression of csv, tsv and jsonl output, e.g. for './export.csv.gz'.
+   * gzip blocks are compressed in parallel on the workers.
+   * @default 'auto'
+   */
+  compression?: OutputCompression;
+
+  /**
+   * Compression level: gzip 1-9, zstd 1-22. 0 uses the codec default
+   * @default 0
+   */
+  compressionLevel?: number;
+
+  /**
+   * Character encoding of csv and tsv output, e.g. 'windows-1252' or
+   * 'UTF-16LE'. Values the encoding cannot represent fail the export.
+   * @default 'utf-8'
+   */
+  encoding?: string;
+
+  /**
+   * CSV/TSV field separator, one character
+   * @default ',' for csv, tab for tsv
+   */
+  csvDelimiter?: string;
+
+  /**
+   * Quote character, or 'none' to never quote. Fields holding the delimiter
+   * or a line break then need csvEscape 'backslash'
+   * @default '"'
+   */
+  csvQuote?: string;
+
+  /**
+   * How quotes are escaped inside quoted fields
+   * @default 'double'
+   */
+  csvEscape?: CSVEscape;
+
+  /**
+   * Write a header row with the column names
+   * @default true
+   */
+  csvHeader?: boolean;
+
+  /**
+   * Quote every field, not only those holding the delimiter, a quote or a
+   * line break
+   * @default false
+   */
+  csvQuoteAll?: boolean;
+
+  /**
+   * End lines with \r\n instead of \n
+   * @default false
+   */
+  csvCrlf?: boolean;
 }
 
 /**
@@ -51,11 +51,58 @@ function getBinaryPath() {
  * Import data from a file into a database
  * @param {Object} options - Import options
  * @param {string} options.file - Path to input file
- * @param {string} options.format - File format (auto, csv, tsv, jsonl, xlsx)
+ * @param {string} options.format - File format (auto, csv, tsv, jsonl, json, xlsx, parquet)
  * @param {string} options.dsn - Database connection string
  * @param {string} options.table - Target table name
  * @param {number} [options.batchSize=5000] - Batch size for inserts
  * @param {number} [options.workers=0] - Number of workers (0 = auto)
+ * @param {string} [options.compression="auto"] - Input compression (auto, none, gzip, zstd, bzip2, xz, zip)
+ * @param {string} [options.encoding="auto"] - Input character encoding, e.g. windows-1252, latin1, UTF-16LE (auto = UTF-8, or UTF-16 by byte order mark)
+ * @param {string} [options.insertMethod="insert"] - Insert method (insert, copy, copy-binary, load-data)
+ * @param {string} [options.onConflict="error"] - Conflict strategy (error, skip, update-all, update-selected)
+ * @param {string[]} [options.conflictKeys] - Key columns identifying duplicate rows
+ * @param {string[]} [options.updateColumns] - Columns to overwrite with update-selected
+ * @param {Object} [options.columnMapping] - Map file columns to table columns (rename, drop, order, constants)
+ * @param {boolean} [options.disableTypeCoercion=false] - Send raw file values instead of converting to column types
+ * @param {boolean} [options.createTable=false] - Create the table from types inferred from the first rows
+ * @param {number} [options.createTableSample=1000] - Rows sampled for type inference
+ * @param {boolean} [options.createTableDryRun=false] - Print the CREATE TABLE statement without running it
+ * @param {string} [options.rejectFile] - JSONL file receiving rejected rows with line number and error
+ * @param {number} [options.maxErrors=0] - Rejected rows tolerated before the import fails
+ * @param {number} [options.maxErrorRatio=0] - Fraction of rejected rows (0-1) tolerated before the import fails
+ * @param {string} [options.checkpointFile] - File recording committed progress so the import can be resumed
+ * @param {boolean} [options.resume=false] - Continue from the checkpoint file instead of starting over
+ * @param {boolean} [options.transactional=false] - Load into a staging table and publish all rows atomically at the end
+ * @param {string} [options.loadStrategy="append"] - What to do with existing rows (append, truncate, delete-where, swap)
+ * @param {string} [options.deleteWhere] - SQL predicate selecting the rows to delete with delete-where
+ * @param {number} [options.parseWorkers=1] - Goroutines parsing csv, tsv and jsonl input in parallel byte ranges
+ * @param {string[]} [options.columns] - Input columns to read, in order, instead of discovering them (jsonl, json, parquet)
+ * @param {string} [options.jsonlDiscovery="first-line"] - How JSONL columns are discovered (first-line, sample, full)
+ * @param {number} [options.jsonlDiscoverySample=1000] - Lines scanned by the sample discovery mode
+ * @param {string} [options.jsonlNested="json"] - Nested JSONL values: json (keep as JSON text) or flatten (one column per path)
+ * @param {string} [options.jsonlSeparator="_"] - Joins the keys of flattened column names (_ or .)
+ * @param {number} [options.jsonlMaxDepth=0] - Most keys in a flattened column name, deeper values stay JSON (0 = no limit)
+ * @param {string} [options.jsonlArrays="json"] - Arrays when flattening: json (keep as JSON text) or explode (one row per element)
+ * @param {string} [options.jsonPointer] - JSON pointer to the array of objects in a json file, e.g. /data
+ * @param {string} [options.xlsxSheet] - XLSX sheet to import, by name
+ * @param {number} [options.xlsxSheetIndex] - XLSX sheet to import, by position starting at 1
+ * @param {number} [options.xlsxHeaderRow=1] - XLSX row holding the column names
+ * @param {number} [options.xlsxDataStartRow] - First XLSX data row (default: the row after the header)
+ * @param {string} [options.xlsxSheets="single"] - XLSX sheets to import: single, combined (all into one table) or per-sheet (one table each)
+ * @param {string} [options.xlsxSheetColumn="sheet_name"] - Column receiving the sheet name with combined
+ * @param {string} [options.xlsxTableTemplate] - Table name for each sheet with per-sheet, e.g. {table}_{sheet}
+ * @param {string} [options.xlsxReader="auto"] - XLSX reader: auto, excelize or stream (low memory, for large workbooks)
+ * @param {number} [options.xlsxMaxSize=104857600] - Largest workbook in bytes read by excelize; auto streams larger ones
+ * @param {boolean} [options.xlsxTypedValues=false] - Read XLSX numbers, booleans, dates and formula results with their types
+ * @param {string} [options.csvDelimiter] - Field separator, one character (default: "," for csv, tab for tsv)
+ * @param {string} [options.csvQuote='"'] - Quote character, or "none" for files that are never quoted
+ * @param {string} [options.csvEscape="double"] - Quotes inside quoted fields: double ("") or backslash (\")
+ * @param {string} [options.csvComment] - Lines starting with this character are skipped
+ * @param {boolean} [options.csvHeader=true] - The first row holds column names; false names them column_1, column_2, ...
+ * @param {number} [options.csvSkipLines=0] - Lines skipped before the header
+ * @param {string} [options.csvRagged="error"] - Rows with too few or too many fields: error, pad or truncate
+ * @param {boolean} [options.csvKeepSpaces=false] - Keep the leading spaces of fields
+ * @param {boolean} [options.csvStrictQuotes=false] - Reject bare and stray quotes instead of keeping them
  * @returns {Promise<void>}
  */
 async function importData(options) {
@@ -66,6 +113,53 @@ async function importData(options) {
     table,
     batchSize = 5000,
     workers = 0,
+    compression = "auto",
+    encoding = "auto",
+    insertMethod = "insert",
+    onConflict = "error",
+    conflictKeys = [],
+    updateColumns = [],
+    columnMapping,
+    disableTypeCoercion = false,
+    createTable = false,
+    createTableSample = 1000,
+    createTableDryRun = false,
+    rejectFile,
+    maxErrors = 0,
+    maxErrorRatio = 0,
+    checkpointFile,
+    resume = false,
+    transactional = false,
+    loadStrategy = "append",
+    deleteWhere,
+    parseWorkers = 1,
+    columns,
+    jsonlDiscovery = "first-line",
+    jsonlDiscoverySample = 1000,
+    jsonlNested = "json",
+    jsonlSeparator = "_",
+    jsonlMaxDepth = 0,
+    jsonlArrays = "json",
+    jsonPointer,
+    xlsxSheet,
+    xlsxSheetIndex = 0,
+    xlsxHeaderRow = 1,
+    xlsxDataStartRow = 0,
+    xlsxSheets = "single",
+    xlsxSheetColumn,
+    xlsxTableTemplate,
+    xlsxReader = "auto",
+    xlsxMaxSize = 100 * 1024 * 1024,
+    xlsxTypedValues = false,
+    csvDelimiter,
+    csvQuote,
+    csvEscape = "double",
+    csvComment,
+    csvHeader = true,
+    csvSkipLines = 0,
+    csvRagged = "error",
+    csvKeepSpaces = false,
+    csvStrictQuotes = false,
   } = options;
 
   // Validate required options
@@ -82,6 +176,53 @@ async function importData(options) {
     batch_size: batchSize,
     workers,
     progress_every: 100000,
+    input_compression: compression,
+    input_encoding: encoding,
+    insert_method: insertMethod,
+    on_conflict: onConflict,
+    conflict_keys: conflictKeys,
+    update_columns: updateColumns,
+    column_mapping: columnMapping,
+    disable_type_coercion: disableTypeCoercion,
+    create_table: createTable,
+    create_table_sample: createTableSample,
+    create_table_dry_run: createTableDryRun,
+    reject_file: rejectFile,
+    max_errors: maxErrors,
+    max_error_ratio: maxErrorRatio,
+    checkpoint_file: checkpointFile,
+    resume,
+    transactional,
+    load_strategy: loadStrategy,
+    delete_where: deleteWhere,
+    parse_workers: parseWorkers,
+    columns,
+    jsonl_discovery: jsonlDiscovery,
+    jsonl_discovery_sample: jsonlDiscoverySample,
+    jsonl_nested: jsonlNested,
+    jsonl_separator: jsonlSeparator,
+    jsonl_max_depth: jsonlMaxDepth,
+    jsonl_arrays: jsonlArrays,
+    json_pointer: jsonPointer,
+    xlsx_sheet: xlsxSheet,
+    xlsx_sheet_index: xlsxSheetIndex,
+    xlsx_header_row: xlsxHeaderRow,
+    xlsx_data_start_row: xlsxDataStartRow,
+    xlsx_sheets: xlsxSheets,
+    xlsx_sheet_column: xlsxSheetColumn,
+    xlsx_table_template: xlsxTableTemplate,
+    xlsx_reader: xlsxReader,
+    xlsx_max_size: xlsxMaxSize,
+    xlsx_typed_values: xlsxTypedValues,
+    csv_delimiter: csvDelimiter,
+    csv_quote: csvQuote,
+    csv_escape: csvEscape,
+    csv_comment: csvComment,
+    csv_no_header: !csvHeader,
+    csv_skip_lines: csvSkipLines,
+    csv_ragged: csvRagged,
+    csv_keep_spaces: csvKeepSpaces,
+    csv_strict_quotes: csvStrictQuotes,
   };
 
   return runEngine(config);
@@ -96,10 +237,35 @@ async function importData(options) {
  * @param {string} options.query - SQL query to execute
  * @param {number} [options.batchSize=5000] - Batch size for reads
  * @param {number} [options.workers=0] - Number of workers (0 = auto)
+ * @param {string} [options.compression="auto"] - Output compression (auto, none, gzip, zstd)
+ * @param {number} [options.compressionLevel=0] - gzip 1-9 or zstd 1-22 (0 = default)
+ * @param {string} [options.encoding="utf-8"] - csv/tsv character encoding, e.g. windows-1252, UTF-16LE
+ * @param {string} [options.csvDelimiter] - Field separator, one character (default: "," for csv, tab for tsv)
+ * @param {string} [options.csvQuote='"'] - Quote character, or "none" to never quote
+ * @param {string} [options.csvEscape="double"] - Quotes inside quoted fields: double ("") or backslash (\")
+ * @param {boolean} [options.csvHeader=true] - Write a header row
+ * @param {boolean} [options.csvQuoteAll=false] - Quote every field
+ * @param {boolean} [options.csvCrlf=false] - End lines with \r\n
  * @returns {Promise<void>}
  */
 async function exportData(options) {
-  const { output, format, dsn, query, batchSize = 5000, workers = 0 } = options;
+  const {
+    output,
+    format,
+    dsn,
+    query,
+    batchSize = 5000,
+    workers = 0,
+    compression = "auto",
+    compressionLevel = 0,
+    encoding = "utf-8",
+    csvDelimiter,
+    csvQuote,
+    csvEscape = "double",
+    csvHeader = true,
+    csvQuoteAll = false,
+    csvCrlf = false,
+  } = options;
 
   // Validate required options
   if (!output) throw new Error("output is required");
@@ -116,6 +282,15 @@ async function exportData(options) {
     batch_size: batchSize,
     workers,
     progress_every: 100000,
+    output_compression: compression,
+    compression_level: compressionLevel,
+    output_encoding: encoding,
+    csv_delimiter: csvDelimiter,
+    csv_quote: csvQuote,
+    csv_escape: csvEscape,
+    csv_no_header: !csvHeader,
+    csv_quote_all: csvQuoteAll,
+    csv_crlf: csvCrlf,
   };
 
   return runEngine(config);